@@ -0,0 +1,454 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var addAuthMethods = []string{"token", "cert", "exec"}
+
+// addOptions holds everything the add wizard asks for; each field can also
+// be given as a flag, in which case the wizard does not prompt for it.
+type addOptions struct {
+	Name           string
+	Server         string
+	CA             string
+	Insecure       bool
+	AuthMethod     string
+	Token          string
+	ClientCert     string
+	ClientKey      string
+	ExecCommand    string
+	ExecArgs       []string
+	ExecAPIVersion string
+	Namespace      string
+	File           string
+	NonInteractive bool
+	SkipValidate   bool
+}
+
+var addOpts addOptions
+
+func promptValue(label, defaultValue string, validate promptui.ValidateFunc) (string, error) {
+	// promptui validates the typed text, which is empty when the default is
+	// accepted with Enter.
+	if validate != nil && defaultValue != "" {
+		check := validate
+		validate = func(input string) error {
+			if strings.TrimSpace(input) == "" {
+				input = defaultValue
+			}
+			return check(input)
+		}
+	}
+	prompt := promptui.Prompt{
+		Label:    label,
+		Default:  defaultValue,
+		Validate: validate,
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return "", promptError(err)
+	}
+	if result = strings.TrimSpace(result); result == "" {
+		return defaultValue, nil
+	}
+	return result, nil
+}
+
+func requireValue(input string) error {
+	if strings.TrimSpace(input) == "" {
+		return fmt.Errorf("a value is required")
+	}
+	return nil
+}
+
+func validateServerURL(input string) error {
+	parsed, err := url.Parse(strings.TrimSpace(input))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return fmt.Errorf("expected a URL like https://host:6443")
+	}
+	return nil
+}
+
+func validateExistingFile(input string) error {
+	if _, err := os.Stat(strings.TrimSpace(input)); err != nil {
+		return fmt.Errorf("file not found")
+	}
+	return nil
+}
+
+// completeAddOptions prompts for every option that was not given as a flag.
+func completeAddOptions(opts *addOptions) error {
+	interactive := !opts.NonInteractive && stdinIsTerminal()
+	var err error
+
+	ask := func(field *string, flag, label, defaultValue string, validate promptui.ValidateFunc) error {
+		if *field != "" {
+			return nil
+		}
+		if !interactive {
+			if validate != nil && validate(defaultValue) != nil {
+				return fmt.Errorf("--%s is required in non-interactive mode", flag)
+			}
+			*field = defaultValue
+			return nil
+		}
+		*field, err = promptValue(label, defaultValue, validate)
+		return err
+	}
+
+	if err := ask(&opts.Name, "name", "Context name", "", requireValue); err != nil {
+		return err
+	}
+	if err := ask(&opts.Server, "server", "API server URL", "", validateServerURL); err != nil {
+		return err
+	}
+	if err := validateServerURL(opts.Server); err != nil {
+		return err
+	}
+	if !opts.Insecure {
+		if opts.CA == "" && interactive {
+			if opts.CA, err = promptCA(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.AuthMethod == "" {
+		if !interactive {
+			return fmt.Errorf("--auth is required in non-interactive mode (token, cert or exec)")
+		}
+		selectAuth := promptui.Select{Label: "Authentication method", Items: addAuthMethods}
+		if _, opts.AuthMethod, err = selectAuth.Run(); err != nil {
+			return promptError(err)
+		}
+	}
+
+	switch opts.AuthMethod {
+	case "token":
+		if err := ask(&opts.Token, "token", "Bearer token", "", requireValue); err != nil {
+			return err
+		}
+	case "cert":
+		if err := ask(&opts.ClientCert, "client-certificate", "Client certificate file", "", validateExistingFile); err != nil {
+			return err
+		}
+		if err := ask(&opts.ClientKey, "client-key", "Client key file", "", validateExistingFile); err != nil {
+			return err
+		}
+	case "exec":
+		if err := ask(&opts.ExecCommand, "exec-command", "Exec plugin command (e.g. aws, kubelogin)", "", requireValue); err != nil {
+			return err
+		}
+		if len(opts.ExecArgs) == 0 && interactive {
+			args, err := promptValue("Exec plugin arguments (space separated)", "", nil)
+			if err != nil {
+				return err
+			}
+			opts.ExecArgs = strings.Fields(args)
+		}
+		if err := ask(&opts.ExecAPIVersion, "exec-api-version", "Exec plugin API version", "client.authentication.k8s.io/v1beta1", requireValue); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown auth method '%s' (expected token, cert or exec)", opts.AuthMethod)
+	}
+
+	if err := ask(&opts.Namespace, "namespace", "Default namespace", "default", nil); err != nil {
+		return err
+	}
+	if err := ask(&opts.File, "file", "Target file in "+configDir, importFileName(opts.Name), requireValue); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const (
+	pemCertificateHeader = "-----BEGIN CERTIFICATE-----"
+	pemCertificateFooter = "-----END CERTIFICATE-----"
+)
+
+// promptCA asks for the CA certificate. It reads plain lines instead of
+// using promptui, whose single-line prompt would drop all but the first line
+// of a pasted PEM block.
+func promptCA() (string, error) {
+	fmt.Print("CA certificate (file path, base64 data or paste the PEM, empty for system CAs): ")
+	var lines []string
+	for {
+		line, err := readStdinLine()
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+		pasting := len(lines) > 0 && strings.HasPrefix(lines[0], pemCertificateHeader)
+		if !pasting || strings.Contains(line, pemCertificateFooter) {
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading the pasted certificate: %v", err)
+		}
+	}
+}
+
+// readStdinLine reads one line from stdin a byte at a time, so nothing typed
+// after it is buffered away from the prompts that follow.
+func readStdinLine() (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+// normalizePEM rebuilds PEM text whose line breaks were lost, e.g. when it
+// was pasted into a single-line prompt, and checks that it is a certificate.
+func normalizePEM(text string) ([]byte, error) {
+	body := strings.TrimSpace(text)
+	body = strings.TrimPrefix(body, pemCertificateHeader)
+	if i := strings.Index(body, pemCertificateFooter); i >= 0 {
+		body = body[:i]
+	}
+	data := []byte(pemCertificateHeader + "\n" + strings.Join(strings.Fields(body), "\n") + "\n" + pemCertificateFooter + "\n")
+	if block, _ := pem.Decode(data); block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("CA is not a valid PEM certificate")
+	}
+	return data, nil
+}
+
+// readCAData accepts a path to a PEM file, pasted PEM text or base64-encoded
+// PEM data.
+func readCAData(ca string) ([]byte, error) {
+	if ca == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(ca), pemCertificateHeader) {
+		return normalizePEM(ca)
+	}
+	if data, err := ioutil.ReadFile(ca); err == nil {
+		return data, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(ca)
+	if err != nil {
+		return nil, fmt.Errorf("CA is neither a readable file nor base64 data")
+	}
+	return data, nil
+}
+
+// buildAddEntries turns the options into typed kubeconfig entries, embedding
+// certificate files as *-data fields.
+func buildAddEntries(opts addOptions) (Cluster, User, Context, error) {
+	clusterDetail := ClusterDetail{
+		Server:                strings.TrimSpace(opts.Server),
+		InsecureSkipTLSVerify: opts.Insecure,
+	}
+	caData, err := readCAData(opts.CA)
+	if err != nil {
+		return Cluster{}, User{}, Context{}, err
+	}
+	if caData != nil {
+		clusterDetail.CertificateAuthorityData = base64.StdEncoding.EncodeToString(caData)
+	}
+
+	var userDetail UserDetail
+	switch opts.AuthMethod {
+	case "token":
+		userDetail.Token = opts.Token
+	case "cert":
+		certData, err := ioutil.ReadFile(opts.ClientCert)
+		if err != nil {
+			return Cluster{}, User{}, Context{}, err
+		}
+		keyData, err := ioutil.ReadFile(opts.ClientKey)
+		if err != nil {
+			return Cluster{}, User{}, Context{}, err
+		}
+		userDetail.ClientCertificateData = base64.StdEncoding.EncodeToString(certData)
+		userDetail.ClientKeyData = base64.StdEncoding.EncodeToString(keyData)
+	case "exec":
+		userDetail.Exec = &ExecConfig{
+			APIVersion:      opts.ExecAPIVersion,
+			Command:         opts.ExecCommand,
+			Args:            opts.ExecArgs,
+			InteractiveMode: "IfAvailable",
+		}
+	}
+
+	namespace := opts.Namespace
+	if namespace == "default" {
+		namespace = ""
+	}
+
+	cluster := Cluster{Name: opts.Name, Cluster: clusterDetail}
+	user := User{Name: opts.Name, User: userDetail}
+	context := Context{Name: opts.Name, Context: ContextDetail{Cluster: opts.Name, User: opts.Name, Namespace: namespace}}
+	return cluster, user, context, nil
+}
+
+// checkConnectivity calls the API server's /version endpoint. Any HTTP
+// response, including 401/403, proves the server is reachable and the TLS
+// setup works.
+func checkConnectivity(cluster Cluster, user User) (int, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cluster.Cluster.InsecureSkipTLSVerify}
+
+	if cluster.Cluster.CertificateAuthorityData != "" {
+		caData, _ := base64.StdEncoding.DecodeString(cluster.Cluster.CertificateAuthorityData)
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return 0, fmt.Errorf("CA data contains no valid PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if user.User.ClientCertificateData != "" {
+		certData, _ := base64.StdEncoding.DecodeString(user.User.ClientCertificateData)
+		keyData, _ := base64.StdEncoding.DecodeString(user.User.ClientKeyData)
+		clientCert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			return 0, fmt.Errorf("invalid client certificate/key: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+
+	request, err := http.NewRequest("GET", strings.TrimRight(cluster.Cluster.Server, "/")+"/version", nil)
+	if err != nil {
+		return 0, err
+	}
+	if user.User.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+user.User.Token)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// appendTypedEntry marshals a typed kubeconfig entry and appends it to the raw
+// list under key, so the rest of the target file is left untouched.
+func appendTypedEntry(configMap map[interface{}]interface{}, key string, entry interface{}) (string, error) {
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	var rawEntry map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &rawEntry); err != nil {
+		return "", err
+	}
+
+	entries, name := addUniqueEntry(rawNamedEntries(configMap, key), rawEntry)
+	setRawNamedEntries(configMap, key, entries)
+	return name, nil
+}
+
+func runAdd(cmd *cobra.Command, args []string) error {
+	opts := addOpts
+	if len(args) > 0 && opts.Name == "" {
+		opts.Name = args[0]
+	}
+
+	if err := completeAddOptions(&opts); err != nil {
+		return err
+	}
+
+	configInfos, _ := loadAllKubeConfigs()
+	if existing := findContextFile(configInfos, opts.Name); existing != "" {
+		return fmt.Errorf("context '%s' already exists in %s", opts.Name, filepath.Base(existing))
+	}
+
+	// --file may point outside the config dir, so the target file needs its
+	// own check.
+	targetPath := resolveTargetFile(opts.File)
+	targetConfig := map[interface{}]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": opts.Name,
+		"preferences":     map[interface{}]interface{}{},
+	}
+	if _, err := os.Stat(targetPath); err == nil {
+		if targetConfig, err = loadRawKubeConfig(targetPath); err != nil {
+			return err
+		}
+		if findRawEntry(targetConfig, "contexts", opts.Name) != nil {
+			return fmt.Errorf("context '%s' already exists in %s", opts.Name, targetPath)
+		}
+	}
+
+	cluster, user, context, err := buildAddEntries(opts)
+	if err != nil {
+		return err
+	}
+
+	if !opts.SkipValidate {
+		fmt.Printf("🔌 Checking connectivity to %s...\n", cluster.Cluster.Server)
+		status, err := checkConnectivity(cluster, user)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Cluster unreachable: %v\n", err)
+			if opts.NonInteractive || !stdinIsTerminal() {
+				return unreachableError("cluster %s is unreachable (use --skip-validate to save anyway)", cluster.Cluster.Server)
+			}
+			confirm := promptui.Prompt{Label: "Save the context anyway", IsConfirm: true}
+			if _, err := confirm.Run(); err != nil {
+				return abortedError("context '%s' not saved", opts.Name)
+			}
+		} else {
+			fmt.Printf("✅ API server responded with HTTP %d\n", status)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0700); err != nil {
+		return err
+	}
+
+	if context.Context.Cluster, err = appendTypedEntry(targetConfig, "clusters", cluster); err != nil {
+		return err
+	}
+	if context.Context.User, err = appendTypedEntry(targetConfig, "users", user); err != nil {
+		return err
+	}
+	if _, err := appendTypedEntry(targetConfig, "contexts", context); err != nil {
+		return err
+	}
+
+	if err := saveRawKubeConfig(targetPath, targetConfig, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Added context '%s' to %s\n", opts.Name, filepath.Base(targetPath))
+	if isProductionEnvironmentCombined(opts.Name, targetPath) {
+		fmt.Println("🔴 This context will be treated as a PRODUCTION environment")
+	}
+	fmt.Printf("💡 Switch to it with: kjx %s\n", opts.Name)
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Entry points recorded in the audit log.
+const (
+	entryDirect = "direct"
+	entrySearch = "search"
+	entryPicker = "picker"
+)
+
+// switchEntryPoint tells the audit log how the current switch was requested.
+var switchEntryPoint = entryDirect
+
+var (
+	auditSince   string
+	auditTier    string
+	auditContext string
+)
+
+// AuditRecord is one line of the audit log.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	Action     string    `json:"action"`
+	Tier       string    `json:"tier"`
+	Context    string    `json:"context"`
+	Namespace  string    `json:"namespace,omitempty"`
+	File       string    `json:"file"`
+	EntryPoint string    `json:"entryPoint"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AuditSettings configures the audit log in config.yaml. With MaxSizeMB set
+// the log is rotated to audit.log.1, audit.log.2, ... keeping MaxBackups.
+type AuditSettings struct {
+	Disabled   bool `yaml:"disabled,omitempty"`
+	MaxSizeMB  int  `yaml:"maxSizeMB,omitempty"`
+	MaxBackups int  `yaml:"maxBackups,omitempty"`
+}
+
+func auditLogPath() string {
+	return filepath.Join(kjxHomeDir(), "audit.log")
+}
+
+func auditUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// contextNamespace reads the namespace a context is set to in filePath.
+func contextNamespace(filePath, contextName string) string {
+	kubeconfig, err := loadKubeConfig(filePath)
+	if err != nil {
+		return ""
+	}
+	if detail, ok := findContextDetail(kubeconfig, contextName); ok {
+		return detail.Namespace
+	}
+	return ""
+}
+
+// auditSwitch records a context or namespace switch. The audit log must never
+// get in the way of a switch, so problems with it are only warnings.
+func auditSwitch(action, contextName, namespace, filePath string, switchErr error) {
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		settings = &Settings{}
+	}
+	if settings.Audit.Disabled {
+		return
+	}
+
+	host, _ := os.Hostname()
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+	record := AuditRecord{
+		Time:       time.Now().UTC(),
+		User:       auditUser(),
+		Host:       host,
+		Action:     action,
+		Tier:       environmentTier(contextName, filePath),
+		Context:    contextName,
+		Namespace:  namespace,
+		File:       filePath,
+		EntryPoint: switchEntryPoint,
+		Outcome:    "success",
+	}
+	if switchErr != nil {
+		record.Outcome = "failure"
+		record.Error = switchErr.Error()
+	}
+
+	if err := appendAuditRecord(record, settings.Audit); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not write audit log: %v\n", err)
+	}
+}
+
+func appendAuditRecord(record AuditRecord, settings AuditSettings) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(kjxHomeDir(), 0700); err != nil {
+		return err
+	}
+	if err := rotateAuditLog(settings); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(auditLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func rotateAuditLog(settings AuditSettings) error {
+	if settings.MaxSizeMB <= 0 {
+		return nil
+	}
+	info, err := os.Stat(auditLogPath())
+	if err != nil || info.Size() < int64(settings.MaxSizeMB)*1024*1024 {
+		return nil
+	}
+
+	backups := settings.MaxBackups
+	if backups <= 0 {
+		backups = 1
+	}
+	os.Remove(fmt.Sprintf("%s.%d", auditLogPath(), backups))
+	for n := backups - 1; n >= 1; n-- {
+		os.Rename(fmt.Sprintf("%s.%d", auditLogPath(), n), fmt.Sprintf("%s.%d", auditLogPath(), n+1))
+	}
+	return os.Rename(auditLogPath(), auditLogPath()+".1")
+}
+
+// auditLogFiles returns the log and its rotated backups, oldest first.
+func auditLogFiles() []string {
+	var files []string
+	for n := 1; ; n++ {
+		backup := fmt.Sprintf("%s.%d", auditLogPath(), n)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		files = append([]string{backup}, files...)
+	}
+	return append(files, auditLogPath())
+}
+
+// parseSince accepts a duration ("24h", "7d") or a date ("2026-01-31",
+// RFC 3339) and returns the earliest time to include.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if duration, err := time.ParseDuration(since); err == nil {
+		return now.Add(-duration), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value '%s' (expected e.g. 24h, 7d or 2026-01-31)", since)
+}
+
+func readAuditRecords() ([]AuditRecord, error) {
+	var records []AuditRecord
+	for _, path := range auditLogFiles() {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reader := bufio.NewReader(file)
+		for lineNumber := 1; ; lineNumber++ {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var record AuditRecord
+				if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: Skipping line %d of %s: %v\n", lineNumber, filepath.Base(path), jsonErr)
+				} else {
+					records = append(records, record)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		file.Close()
+	}
+	return records, nil
+}
+
+func runAuditLog(cmd *cobra.Command, args []string) error {
+	if outputFormat != "" && outputFormat != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected json)", outputFormat)
+	}
+	if auditTier != "" && auditTier != "prod" && auditTier != "non-prod" {
+		return fmt.Errorf("unknown --tier value '%s' (expected prod or non-prod)", auditTier)
+	}
+
+	var since time.Time
+	if auditSince != "" {
+		var err error
+		if since, err = parseSince(auditSince, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	records, err := readAuditRecords()
+	if err != nil {
+		return err
+	}
+
+	var matched []AuditRecord
+	for _, record := range records {
+		if record.Time.Before(since) {
+			continue
+		}
+		if auditTier != "" && record.Tier != auditTier {
+			continue
+		}
+		if auditContext != "" && record.Context != auditContext {
+			continue
+		}
+		matched = append(matched, record)
+	}
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, record := range matched {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No matching audit records")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tHOST\tTIER\tCONTEXT\tNAMESPACE\tENTRY\tOUTCOME")
+	for _, record := range matched {
+		outcome := record.Outcome
+		if record.Error != "" {
+			outcome += ": " + record.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.Time.Local().Format("2006-01-02 15:04:05"), record.User, record.Host, record.Tier,
+			record.Context, record.Namespace, record.EntryPoint, outcome)
+	}
+	return w.Flush()
+}
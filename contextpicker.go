@@ -0,0 +1,377 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Key bindings of the context picker, in the names fzf uses.
+const (
+	pickerKeyNamespaces = "ctrl-o"
+	pickerKeyCopy       = "ctrl-y"
+	pickerKeyFavorite   = "ctrl-f"
+	pickerKeyToggleProd = "ctrl-t"
+)
+
+var contextPickerKeys = []PickerKey{
+	{Name: pickerKeyNamespaces, Description: "namespaces"},
+	{Name: pickerKeyCopy, Description: "copy name"},
+	{Name: pickerKeyFavorite, Description: "favorite"},
+	{Name: pickerKeyToggleProd, Description: "show/hide prod"},
+}
+
+// contextGroupValue is the value a context is grouped under: its file, its
+// tier, or the value of a tag.
+func contextGroupValue(configInfo ConfigInfo, contextName, key string) string {
+	switch key {
+	case "", "file":
+		return filepath.Base(configInfo.FilePath)
+	case "tier":
+		return environmentTier(contextName, configInfo.FilePath)
+	}
+	if value := configInfo.Tags[contextName][key]; value != "" {
+		return value
+	}
+	return "(none)"
+}
+
+func contextDetails(record ContextRecord) string {
+	tierIndicator := ""
+	if record.Tier == "prod" {
+		tierIndicator = " 🔴"
+	}
+
+	lines := []string{
+		"--------- Details ---------",
+		"Cluster:   " + record.Cluster,
+		"Server:    " + record.Server,
+		"User:      " + record.User,
+		"Namespace: " + record.Namespace,
+		"File:      " + filepath.Base(record.File),
+		"Tier:      " + record.Tier + tierIndicator,
+	}
+	if tags := formatTags(record.Tags); tags != "" {
+		lines = append(lines, "Tags:      "+tags)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contextPickerItems builds one picker item per context: pinned first, then
+// by frecency and name, kept together by section when --group-by is given.
+// The returned names line up with the items. Non-nil matches limits the
+// items to those contexts, kept in the order given.
+func contextPickerItems(configInfos []ConfigInfo, showProd bool, state *State, matches []string) ([]PickerItem, []string) {
+	type entry struct {
+		item PickerItem
+		name string
+	}
+
+	var names []string
+	sections := make(map[string]string)
+	for _, configInfo := range configInfos {
+		for _, context := range configInfo.Contexts {
+			if !showProd && isProductionEnvironmentCombined(context, configInfo.FilePath) {
+				continue
+			}
+			names = append(names, context)
+			sections[context] = contextGroupValue(configInfo, context, groupByTag)
+		}
+	}
+
+	now := time.Now()
+	if matches != nil {
+		var ordered []string
+		for _, match := range matches {
+			if containsString(names, match) {
+				ordered = append(ordered, match)
+			}
+		}
+		names = ordered
+	} else {
+		state.sortByUsage(names, now)
+	}
+
+	var entries []entry
+	for _, record := range buildContextRecords(configInfos, names) {
+		label := record.Name
+		if record.Tier == "prod" {
+			label += " 🔴"
+		}
+		if state.isFavorite(record.Name) {
+			label += " ★"
+		}
+		if namespace := state.Namespaces[record.Name]; namespace != "" && !noStickyNamespace {
+			label += " ↳ " + namespace
+		}
+		entries = append(entries, entry{
+			item: PickerItem{
+				Label:   label,
+				Key:     record.Name,
+				Section: sections[record.Name],
+				Details: contextDetails(record),
+				Boost:   state.usageBoost(record.Name, now),
+			},
+			name: record.Name,
+		})
+	}
+
+	if groupByTag != "" {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].item.Section < entries[j].item.Section
+		})
+	}
+
+	items := make([]PickerItem, len(entries))
+	names = names[:0]
+	for i, entry := range entries {
+		items[i] = entry.item
+		names = append(names, entry.name)
+	}
+	return items, names
+}
+
+func pickNamespaceByDefault() bool {
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return false
+	}
+	return settings.PickNamespace
+}
+
+// copyToClipboard uses the first clipboard tool it finds and falls back to
+// the OSC 52 terminal escape, which most terminal emulators understand.
+func copyToClipboard(text string) error {
+	tools := [][]string{
+		{"pbcopy"},
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"clip.exe"},
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool[0], tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+
+	fmt.Fprintf(os.Stderr, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return nil
+}
+
+// namespacePrefetchCount bounds how many contexts get their namespaces
+// fetched in the background while the user is choosing.
+const namespacePrefetchCount = 3
+
+type namespacePrefetch struct {
+	namespaces []string
+	err        error
+	done       chan struct{}
+}
+
+// namespacePrefetchCandidates returns the contexts most likely to be picked:
+// pinned ones first, then the most frecent. Contexts that were never used or
+// already have a fresh cache entry are skipped, so kjx does not query
+// clusters nobody works with.
+func namespacePrefetchCandidates(names []string, state *State) []string {
+	now := time.Now()
+	cache := loadNamespaceCache()
+	ordered := append([]string(nil), names...)
+	state.sortByUsage(ordered, now)
+
+	var candidates []string
+	for _, name := range ordered {
+		if len(candidates) == namespacePrefetchCount {
+			break
+		}
+		if !state.isFavorite(name) && state.frecency(name, now) == 0 {
+			continue
+		}
+		if _, ok := cache.fresh(name); ok {
+			continue
+		}
+		candidates = append(candidates, name)
+	}
+	return candidates
+}
+
+// prefetchNamespaces starts fetching the namespaces of the prefetch
+// candidates among names.
+func prefetchNamespaces(configInfos []ConfigInfo, names []string, state *State) map[string]*namespacePrefetch {
+	prefetches := make(map[string]*namespacePrefetch)
+	for _, name := range namespacePrefetchCandidates(names, state) {
+		prefetch := &namespacePrefetch{done: make(chan struct{})}
+		prefetches[name] = prefetch
+		target := NamespaceTarget{Context: name, File: findContextFile(configInfos, name), Outside: true}
+		go func() {
+			prefetch.namespaces, prefetch.err = getLiveNamespaces(target)
+			close(prefetch.done)
+		}()
+	}
+	return prefetches
+}
+
+// pickerNamespaces returns the namespaces for the second picker step, from a
+// prefetch if one was started for the context.
+func pickerNamespaces(target NamespaceTarget, prefetches map[string]*namespacePrefetch) ([]string, error) {
+	if prefetch, ok := prefetches[target.Context]; ok {
+		<-prefetch.done
+		if prefetch.err == nil {
+			storeCachedNamespaces(map[string][]string{target.Context: prefetch.namespaces})
+			return prefetch.namespaces, nil
+		}
+	}
+	return cachedNamespaces(target)
+}
+
+// selectNamespaceStep is the namespace step after a context was picked.
+// Escape keeps the namespace the context switched to.
+func selectNamespaceStep(target NamespaceTarget, prefetches map[string]*namespacePrefetch) error {
+	current := contextNamespace(target.File, target.Context)
+	if current == "" {
+		current = "default"
+	}
+
+	namespaces, err := pickerNamespaces(target, prefetches)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not get live namespaces (%v), using defaults\n", err)
+		namespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+	}
+
+	items := plainPickerItems(namespaces)
+	for i, namespace := range namespaces {
+		if namespace == current {
+			items[i].Label += " (current)"
+		}
+	}
+
+	picked, err := pick(PickRequest{
+		Label: fmt.Sprintf("Select namespace in '%s' (Esc keeps '%s')", target.Context, current),
+		Items: items,
+		Size:  15,
+	})
+	if err != nil && exitCodeFor(err) != exitAborted {
+		return err
+	}
+	if err != nil || namespaces[picked.Index] == current {
+		fmt.Printf("📦 Keeping namespace '%s'\n", current)
+		return nil
+	}
+	switchEntryPoint = entryPicker
+	return switchToNamespace(namespaces[picked.Index], target)
+}
+
+// ContextPickerOptions adjust the context picker.
+type ContextPickerOptions struct {
+	StartInSearch bool
+	// SearchTerm and Matches limit the picker to the results of kjx -s.
+	SearchTerm string
+	Matches    []string
+	// NamespaceTerm is the namespace part of a "context/namespace" search,
+	// resolved in the picked context instead of the namespace step.
+	NamespaceTerm string
+}
+
+// interactiveContextSelect is the context picker behind `kjx -i` and `kjx -s`.
+// Key bindings that do not switch context reopen the picker afterwards. With
+// --ns (or pickNamespace in config.yaml) a namespace picker follows.
+func interactiveContextSelect(configInfos []ConfigInfo, opts ContextPickerOptions) error {
+	showProd := true
+	namespaceStep := pickNamespaceStep || pickNamespaceByDefault()
+	var prefetches map[string]*namespacePrefetch
+
+	for {
+		state := loadStateOrEmpty()
+
+		items, names := contextPickerItems(configInfos, showProd, state, opts.Matches)
+		if namespaceStep && opts.NamespaceTerm == "" && prefetches == nil {
+			prefetches = prefetchNamespaces(configInfos, names, state)
+		}
+		if len(items) == 0 {
+			if !showProd {
+				fmt.Fprintln(os.Stderr, "All matching contexts are production contexts, showing them again")
+				showProd = true
+				continue
+			}
+			return notFoundError("no contexts available")
+		}
+
+		label := "Select context"
+		if opts.Matches != nil {
+			label = fmt.Sprintf("Select from contexts matching '%s'", opts.SearchTerm)
+		}
+		if !showProd {
+			label += ", prod hidden"
+		}
+		label += " (type to search/filter)"
+
+		picked, err := pick(PickRequest{
+			Label:         label,
+			Items:         items,
+			Size:          10,
+			Preview:       true,
+			StartInSearch: opts.StartInSearch,
+			Keys:          contextPickerKeys,
+		})
+		if err != nil {
+			return err
+		}
+
+		contextName := names[picked.Index]
+		filePath := findContextFile(configInfos, contextName)
+
+		switch picked.Key {
+		case pickerKeyToggleProd:
+			showProd = !showProd
+			continue
+		case pickerKeyCopy:
+			if err := copyToClipboard(contextName); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not copy '%s': %v\n", contextName, err)
+			} else {
+				fmt.Printf("📋 Copied '%s'\n", contextName)
+			}
+			continue
+		case pickerKeyFavorite:
+			if state.toggleFavorite(contextName) {
+				fmt.Printf("★ Marked '%s' as favorite\n", contextName)
+			} else {
+				fmt.Printf("☆ Removed '%s' from favorites\n", contextName)
+			}
+			if err := saveState(state); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if isProductionEnvironmentCombined(contextName, filePath) {
+			showProductionWarning(contextName, filePath)
+		}
+
+		namespace := ""
+		if opts.NamespaceTerm != "" {
+			target := NamespaceTarget{Context: contextName, File: filePath, Outside: true}
+			if namespace, err = searchTargetNamespace(target, opts.NamespaceTerm); err != nil {
+				return err
+			}
+		}
+
+		switchEntryPoint = entryPicker
+		if err := setKubeConfig(filePath, contextName, namespace); err != nil {
+			return err
+		}
+
+		if namespace == "" && (namespaceStep || picked.Key == pickerKeyNamespaces) {
+			return selectNamespaceStep(NamespaceTarget{Context: contextName, File: filePath, Outside: true}, prefetches)
+		}
+		return nil
+	}
+}
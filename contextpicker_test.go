@@ -0,0 +1,36 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNamespacePrefetchCandidates(t *testing.T) {
+	t.Setenv("KJX_HOME", t.TempDir())
+	now := time.Now()
+	state := &State{
+		Favorites: []string{"pinned"},
+		Usage: map[string]ContextUsage{
+			"daily":   {Count: 10, LastUsed: now.Add(-time.Minute)},
+			"weekly":  {Count: 3, LastUsed: now.Add(-3 * 24 * time.Hour)},
+			"cached":  {Count: 50, LastUsed: now},
+			"monthly": {Count: 1, LastUsed: now.Add(-30 * 24 * time.Hour)},
+		},
+	}
+	storeCachedNamespaces(map[string][]string{"cached": {"default"}})
+
+	names := []string{"never-used", "monthly", "weekly", "cached", "daily", "pinned"}
+	got := namespacePrefetchCandidates(names, state)
+	want := []string{"pinned", "daily", "weekly"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("namespacePrefetchCandidates() = %q, want %q", got, want)
+	}
+}
+
+func TestNamespacePrefetchCandidatesSkipsUnusedContexts(t *testing.T) {
+	t.Setenv("KJX_HOME", t.TempDir())
+	if got := namespacePrefetchCandidates([]string{"prod-eu", "prod-us", "staging"}, &State{}); len(got) != 0 {
+		t.Errorf("namespacePrefetchCandidates() = %q, want none", got)
+	}
+}
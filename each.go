@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+// exitTimeout is what a timed-out context reports, the same as timeout(1).
+const exitTimeout = 124
+
+var (
+	eachSearch      string
+	eachTier        string
+	eachConcurrency int
+	eachTimeout     time.Duration
+	eachOutput      string
+)
+
+// EachResult is the outcome of the command in one context.
+type EachResult struct {
+	Context  string
+	ExitCode int
+	TimedOut bool
+	Duration time.Duration
+	Err      error
+}
+
+// prefixWriter writes whole lines to out, each prefixed, so the output of
+// contexts running in parallel does not interleave mid-line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:i])
+		w.mu.Unlock()
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line that did not end in a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf)
+		w.mu.Unlock()
+		w.buf = nil
+	}
+}
+
+// selectContexts applies --tag, then the search term and the tier, as kjx
+// each and kjx find do.
+func selectContexts(configInfos []ConfigInfo, searchTerm, tier string) ([]string, error) {
+	filters, err := parseTagFilters(tagFilters)
+	if err != nil {
+		return nil, err
+	}
+	configInfos = filterConfigInfosByTags(configInfos, filters)
+
+	names := allContextNames(configInfos)
+	if searchTerm != "" {
+		if names, err = searchContexts(configInfos, searchTerm); err != nil {
+			return nil, err
+		}
+	}
+
+	if tier != "" {
+		if tier != "prod" && tier != "non-prod" {
+			return nil, fmt.Errorf("unknown --tier value '%s' (expected prod or non-prod)", tier)
+		}
+		var tiered []string
+		for _, name := range names {
+			if environmentTier(name, findContextFile(configInfos, name)) == tier {
+				tiered = append(tiered, name)
+			}
+		}
+		names = tiered
+	}
+
+	if len(names) == 0 {
+		return nil, notFoundError("no contexts match the given selection")
+	}
+	return names, nil
+}
+
+// confirmProductionBatch asks once before running against several
+// production contexts; see confirmProductionAction for the single case.
+func confirmProductionBatch(configInfos []ConfigInfo, contextNames []string) error {
+	var prod []string
+	for _, name := range contextNames {
+		if isProductionEnvironmentCombined(name, findContextFile(configInfos, name)) {
+			prod = append(prod, name)
+		}
+	}
+	if len(prod) == 0 || assumeYes {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "⚠️  WARNING: PRODUCTION ENVIRONMENTS SELECTED!")
+	for _, name := range prod {
+		fmt.Fprintf(os.Stderr, "🔴 %s\n", name)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if !stdinIsTerminal() {
+		return refusedError("refusing to run on %d PRODUCTION context(s) without confirmation (use --yes)", len(prod))
+	}
+
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Really run on %d PRODUCTION context(s)", len(prod)),
+		IsConfirm: true,
+	}
+	if _, err := prompt.Run(); err != nil {
+		return abortedError("aborted by user")
+	}
+	return nil
+}
+
+// signalCause is why the contexts of kjx each were cancelled when kjx got
+// SIGTERM or SIGHUP; the signal is passed on to the running commands.
+type signalCause struct {
+	signal os.Signal
+}
+
+func (c signalCause) Error() string {
+	return "received " + c.signal.String()
+}
+
+func runInContext(terminated context.Context, configInfos []ConfigInfo, contextName string, command []string, stdout, stderr io.Writer) EachResult {
+	result := EachResult{Context: contextName}
+	started := time.Now()
+
+	kubeconfigPath, cleanup, err := writeIsolatedKubeConfig(configInfos, contextName, "")
+	if err != nil {
+		result.ExitCode, result.Err = exitError, err
+		result.Duration = time.Since(started)
+		return result
+	}
+	defer cleanup()
+
+	ctx := terminated
+	if eachTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, eachTimeout)
+		defer cancel()
+	}
+
+	child := exec.CommandContext(ctx, command[0], command[1:]...)
+	child.Env = isolatedEnv(kubeconfigPath, contextName, "")
+	child.Stdout = stdout
+	child.Stderr = stderr
+	// A timeout kills the command; a signal sent to kjx is passed on.
+	child.Cancel = func() error {
+		if cause, ok := context.Cause(ctx).(signalCause); ok {
+			return child.Process.Signal(cause.signal)
+		}
+		return child.Process.Kill()
+	}
+	// Grandchildren may hold the output pipes open after a timeout kill.
+	child.WaitDelay = time.Second
+
+	err = child.Run()
+	result.Duration = time.Since(started)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode, result.TimedOut = exitTimeout, true
+	case err == nil:
+	default:
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode, result.Err = exitError, err
+		}
+	}
+	return result
+}
+
+func printEachSummary(results []EachResult) (failed int) {
+	fmt.Fprintln(os.Stderr)
+	table := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CONTEXT\tSTATUS\tEXIT\tDURATION")
+
+	succeeded, timedOut := 0, 0
+	for _, result := range results {
+		status := "✅ ok"
+		switch {
+		case result.TimedOut:
+			status = "⏱️  timeout"
+			timedOut++
+		case result.Err != nil:
+			status = "❌ " + result.Err.Error()
+			failed++
+		case result.ExitCode != 0:
+			status = "❌ failed"
+			failed++
+		default:
+			succeeded++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", result.Context, status, result.ExitCode, result.Duration.Round(time.Millisecond))
+	}
+	table.Flush()
+
+	fmt.Fprintf(os.Stderr, "\n%d context(s): %d succeeded, %d failed, %d timed out\n", len(results), succeeded, failed, timedOut)
+	return failed + timedOut
+}
+
+func runEach(cmd *cobra.Command, args []string) error {
+	command := args
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("no command given (usage: kjx each [selection] -- <command>...)")
+	}
+	if eachOutput != "prefix" && eachOutput != "group" {
+		return fmt.Errorf("unknown --output value '%s' (expected prefix or group)", eachOutput)
+	}
+	if eachConcurrency < 1 {
+		eachConcurrency = 1
+	}
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	contextNames, err := selectContexts(configInfos, eachSearch, eachTier)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmProductionBatch(configInfos, contextNames); err != nil {
+		return err
+	}
+
+	// Ctrl-C reaches the children directly, SIGTERM and SIGHUP are passed on
+	// to them. Either way kjx stops starting new ones and waits for the
+	// running ones so it can still clean up and summarize.
+	interrupted, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	terminated, terminate := context.WithCancelCause(context.Background())
+	defer terminate(nil)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, terminationSignals...)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cancel()
+			if sig != os.Interrupt {
+				terminate(signalCause{sig})
+			}
+		}
+	}()
+
+	width := 0
+	for _, name := range contextNames {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	var outputMu sync.Mutex
+	results := make([]EachResult, len(contextNames))
+	slots := make(chan struct{}, eachConcurrency)
+	var wg sync.WaitGroup
+
+	for i, contextName := range contextNames {
+		slots <- struct{}{}
+		if interrupted.Err() != nil {
+			<-slots
+			results[i] = EachResult{Context: contextName, ExitCode: exitAborted, Err: fmt.Errorf("not started (interrupted)")}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, contextName string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if eachOutput == "group" {
+				var buffer bytes.Buffer
+				results[i] = runInContext(terminated, configInfos, contextName, command, &buffer, &buffer)
+				outputMu.Lock()
+				fmt.Printf("═══ %s (exit %d, %s) ═══\n", contextName, results[i].ExitCode, results[i].Duration.Round(time.Millisecond))
+				os.Stdout.Write(buffer.Bytes())
+				if buffer.Len() > 0 && !bytes.HasSuffix(buffer.Bytes(), []byte("\n")) {
+					fmt.Println()
+				}
+				outputMu.Unlock()
+				return
+			}
+
+			prefix := fmt.Sprintf("[%-*s] ", width, contextName)
+			stdout := &prefixWriter{mu: &outputMu, out: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &outputMu, out: os.Stderr, prefix: prefix}
+			results[i] = runInContext(terminated, configInfos, contextName, command, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+		}(i, contextName)
+	}
+	wg.Wait()
+
+	if failed := printEachSummary(results); failed > 0 {
+		return &ExitStatus{Code: exitError}
+	}
+	return nil
+}
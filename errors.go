@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Exit codes returned by kjx. Scripts depend on these values, so never
+// renumber them.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitNotFound    = 2
+	exitAmbiguous   = 3
+	exitUnreachable = 4
+	exitRefused     = 5
+	exitAborted     = 6
+)
+
+// CommandError carries the exit code a failure should terminate kjx with.
+type CommandError struct {
+	Code int
+	Err  error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func newCommandError(code int, format string, args ...interface{}) error {
+	return &CommandError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+func notFoundError(format string, args ...interface{}) error {
+	return newCommandError(exitNotFound, format, args...)
+}
+
+func ambiguousError(format string, args ...interface{}) error {
+	return newCommandError(exitAmbiguous, format, args...)
+}
+
+func unreachableError(format string, args ...interface{}) error {
+	return newCommandError(exitUnreachable, format, args...)
+}
+
+func refusedError(format string, args ...interface{}) error {
+	return newCommandError(exitRefused, format, args...)
+}
+
+func abortedError(format string, args ...interface{}) error {
+	return newCommandError(exitAborted, format, args...)
+}
+
+// ExitStatus ends kjx with the exit status of a command it ran for the user,
+// without printing an error of its own.
+type ExitStatus struct {
+	Code int
+}
+
+func (e *ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// promptError turns promptui's cancellation errors into an aborted error and
+// passes anything else through untouched.
+func promptError(err error) error {
+	if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) || errors.Is(err, promptui.ErrAbort) {
+		return abortedError("aborted by user")
+	}
+	return err
+}
+
+func exitCodeFor(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var commandErr *CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code
+	}
+
+	var exitStatus *ExitStatus
+	if errors.As(err, &exitStatus) {
+		return exitStatus.Code
+	}
+
+	return exitError
+}
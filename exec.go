@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// parseContextTarget splits "context/namespace" or "context:namespace" into
+// its parts. Context names may contain slashes and colons themselves (EKS ARNs
+// do), so a target that names an existing context is taken as a whole, and
+// otherwise the last slash, then the last colon, splits.
+func parseContextTarget(configInfos []ConfigInfo, target string) (contextName, namespace string, err error) {
+	if findContextFile(configInfos, target) != "" {
+		return target, "", nil
+	}
+
+	contextName = target
+	for _, separator := range []string{"/", ":"} {
+		i := strings.LastIndex(target, separator)
+		if i <= 0 || i == len(target)-1 {
+			continue
+		}
+		if findContextFile(configInfos, target[:i]) != "" {
+			return target[:i], target[i+1:], nil
+		}
+		if contextName == target {
+			contextName = target[:i]
+		}
+	}
+
+	return "", "", notFoundError("context '%s' not found", contextName)
+}
+
+// writeIsolatedKubeConfig writes a flattened kubeconfig holding only
+// contextName, with namespace as its default if given, to a private temporary
+// file. The source kubeconfig is never modified. Call cleanup when done.
+func writeIsolatedKubeConfig(configInfos []ConfigInfo, contextName, namespace string) (path string, cleanup func(), err error) {
+	filePath := findContextFile(configInfos, contextName)
+	if filePath == "" {
+		return "", nil, notFoundError("context '%s' not found", contextName)
+	}
+
+	rawConfig, err := loadRawKubeConfig(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var builder standaloneBuilder
+	if err := builder.add(rawConfig, filePath, contextName, contextName); err != nil {
+		return "", nil, err
+	}
+	if namespace != "" {
+		rawEntryDetail(builder.contexts[0], "context")["namespace"] = namespace
+	}
+
+	data, err := yaml.Marshal(builder.kubeconfig(contextName))
+	if err != nil {
+		return "", nil, err
+	}
+
+	// TempFile creates the file with 0600 permissions.
+	file, err := ioutil.TempFile("", "kjx-"+unsafeFileNameChars.ReplaceAllString(contextName, "-")+"-*.yaml")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(file.Name()) }
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// isolatedEnv returns the current environment with KUBECONFIG pointing at the
+// isolated file and KJX_CONTEXT/KJX_NAMESPACE describing it.
+func isolatedEnv(kubeconfigPath, contextName, namespace string) []string {
+	var env []string
+	for _, entry := range os.Environ() {
+		if strings.HasPrefix(entry, "KUBECONFIG=") || strings.HasPrefix(entry, "KJX_CONTEXT=") || strings.HasPrefix(entry, "KJX_NAMESPACE=") {
+			continue
+		}
+		env = append(env, entry)
+	}
+	env = append(env, "KUBECONFIG="+kubeconfigPath, "KJX_CONTEXT="+contextName)
+	if namespace != "" {
+		env = append(env, "KJX_NAMESPACE="+namespace)
+	}
+	return env
+}
+
+// terminationSignals are the signals kjx traps while a command runs, so it
+// survives long enough to remove the temporary kubeconfig.
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// runCommandStatus runs cmd and returns its exit status. Interrupts are left
+// to the child, which gets them from the terminal too; SIGTERM and SIGHUP,
+// which may only reach kjx, are passed on. Either way kjx stays alive to clean
+// up after the child.
+func runCommandStatus(cmd *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, terminationSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("running %s: %v", cmd.Args[0], err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig != os.Interrupt {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			// Exit like a shell does for a command killed by a signal.
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("running %s: %v", cmd.Args[0], err)
+	}
+	return 0, nil
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	// kjx flags may come before or after the target; everything after "--"
+	// belongs to the command being run.
+	if dash := cmd.ArgsLenAtDash(); dash == 0 || dash > 1 {
+		return fmt.Errorf("expected one target before '--', got %d (usage: kjx exec <context>[/<namespace>] -- <command>...)", dash)
+	}
+	target, command := args[0], args[1:]
+	if len(command) == 0 {
+		return fmt.Errorf("no command given (usage: kjx exec <context>[/<namespace>] -- <command>...)")
+	}
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	contextName, namespace, err := parseContextTarget(configInfos, target)
+	if err != nil {
+		return err
+	}
+
+	filePath := findContextFile(configInfos, contextName)
+	if namespace != "" {
+		target := NamespaceTarget{Context: contextName, File: filePath, Outside: true}
+		if err := validateNamespace(target, namespace); err != nil {
+			return err
+		}
+	}
+
+	if err := confirmProductionAction("exec", contextName, filePath); err != nil {
+		return err
+	}
+
+	kubeconfigPath, cleanup, err := writeIsolatedKubeConfig(configInfos, contextName, namespace)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Env = isolatedEnv(kubeconfigPath, contextName, namespace)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	status, err := runCommandStatus(child)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return &ExitStatus{Code: status}
+	}
+	return nil
+}
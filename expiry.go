@@ -0,0 +1,271 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var expiryWarnDays int
+
+type CredentialExpiry struct {
+	Kind    string
+	Source  string
+	Expires time.Time
+	Err     error
+}
+
+func resolveConfigPath(configFilePath, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFilePath), path)
+}
+
+func findContextDetail(kubeconfig *KubeConfig, contextName string) (ContextDetail, bool) {
+	for _, ctx := range kubeconfig.Contexts {
+		if ctx.Name == contextName {
+			return ctx.Context, true
+		}
+	}
+	return ContextDetail{}, false
+}
+
+func findUser(kubeconfig *KubeConfig, userName string) (UserDetail, bool) {
+	for _, user := range kubeconfig.Users {
+		if user.Name == userName {
+			return user.User, true
+		}
+	}
+	return UserDetail{}, false
+}
+
+func certificateExpiry(pemData []byte) (time.Time, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("no PEM certificate found")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.NotAfter, nil
+}
+
+// tokenExpiry reads the exp claim of a JWT. Opaque tokens and JWTs without
+// an exp claim report ok=false.
+func tokenExpiry(token string) (expires time.Time, ok bool, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid JWT payload: %v", err)
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid JWT claims: %v", err)
+	}
+	if claims.Exp == nil {
+		return time.Time{}, false, nil
+	}
+
+	return time.Unix(int64(*claims.Exp), 0), true, nil
+}
+
+func userCredentialExpiries(user UserDetail, configFilePath string) []CredentialExpiry {
+	var expiries []CredentialExpiry
+
+	if user.ClientCertificateData != "" {
+		entry := CredentialExpiry{Kind: "client-cert", Source: "embedded"}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(user.ClientCertificateData))
+		if err != nil {
+			entry.Err = fmt.Errorf("invalid base64: %v", err)
+		} else {
+			entry.Expires, entry.Err = certificateExpiry(data)
+		}
+		expiries = append(expiries, entry)
+	} else if user.ClientCertificate != "" {
+		certPath := resolveConfigPath(configFilePath, user.ClientCertificate)
+		entry := CredentialExpiry{Kind: "client-cert", Source: certPath}
+		data, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			entry.Err = err
+		} else {
+			entry.Expires, entry.Err = certificateExpiry(data)
+		}
+		expiries = append(expiries, entry)
+	}
+
+	if user.Token != "" {
+		if expires, ok, err := tokenExpiry(user.Token); ok || err != nil {
+			expiries = append(expiries, CredentialExpiry{Kind: "token", Source: "embedded", Expires: expires, Err: err})
+		}
+	} else if user.TokenFile != "" {
+		tokenPath := resolveConfigPath(configFilePath, user.TokenFile)
+		data, err := ioutil.ReadFile(tokenPath)
+		if err != nil {
+			expiries = append(expiries, CredentialExpiry{Kind: "token", Source: tokenPath, Err: err})
+		} else if expires, ok, err := tokenExpiry(strings.TrimSpace(string(data))); ok || err != nil {
+			expiries = append(expiries, CredentialExpiry{Kind: "token", Source: tokenPath, Expires: expires, Err: err})
+		}
+	}
+
+	if user.AuthProvider != nil {
+		if idToken := user.AuthProvider.Config["id-token"]; idToken != "" {
+			if expires, ok, err := tokenExpiry(idToken); ok || err != nil {
+				expiries = append(expiries, CredentialExpiry{Kind: "id-token", Source: "auth-provider", Expires: expires, Err: err})
+			}
+		}
+	}
+
+	return expiries
+}
+
+func contextCredentialExpiries(kubeconfig *KubeConfig, contextName, configFilePath string) []CredentialExpiry {
+	detail, ok := findContextDetail(kubeconfig, contextName)
+	if !ok {
+		return nil
+	}
+
+	user, ok := findUser(kubeconfig, detail.User)
+	if !ok {
+		return nil
+	}
+
+	return userCredentialExpiries(user, configFilePath)
+}
+
+func expiryWarnWindow() time.Duration {
+	return time.Duration(expiryWarnDays) * 24 * time.Hour
+}
+
+func expiryIndicator(entry CredentialExpiry, now time.Time) string {
+	switch {
+	case entry.Err != nil:
+		return "❓"
+	case !entry.Expires.After(now):
+		return "❌"
+	case entry.Expires.Sub(now) <= expiryWarnWindow():
+		return "⚠️ "
+	default:
+		return "✅"
+	}
+}
+
+func describeExpiry(entry CredentialExpiry, now time.Time) string {
+	if entry.Err != nil {
+		return fmt.Sprintf("unreadable (%v)", entry.Err)
+	}
+
+	date := entry.Expires.Local().Format("2006-01-02 15:04")
+	remaining := entry.Expires.Sub(now)
+	if remaining <= 0 {
+		return fmt.Sprintf("expired %s (%s ago)", date, humanizeDuration(-remaining))
+	}
+	return fmt.Sprintf("expires %s (in %s)", date, humanizeDuration(remaining))
+}
+
+func humanizeDuration(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days >= 2:
+		return fmt.Sprintf("%d days", days)
+	case d >= time.Hour:
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+}
+
+func needsExpiryWarning(entry CredentialExpiry, now time.Time) bool {
+	return entry.Err == nil && entry.Expires.Sub(now) <= expiryWarnWindow()
+}
+
+func showCredentialExpiry(configFilePath, contextName string) {
+	kubeconfig, err := loadKubeConfig(configFilePath)
+	if err != nil {
+		return
+	}
+
+	expiries := contextCredentialExpiries(kubeconfig, contextName, configFilePath)
+	if len(expiries) == 0 {
+		return
+	}
+
+	now := time.Now()
+	warn := false
+	for _, entry := range expiries {
+		fmt.Printf("🔐 Credential (%s): %s %s\n", entry.Kind, expiryIndicator(entry, now), describeExpiry(entry, now))
+		if needsExpiryWarning(entry, now) {
+			warn = true
+		}
+	}
+
+	if warn {
+		fmt.Printf("⚠️  Credentials for '%s' have expired or expire within %d days - renew them soon!\n", contextName, expiryWarnDays)
+	}
+}
+
+func runExpiry(cmd *cobra.Command, args []string) error {
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	now := time.Now()
+	expiring := 0
+
+	fmt.Printf("Credential expiry for contexts in %s (warning window: %d days):\n\n", configDir, expiryWarnDays)
+
+	for _, configInfo := range configInfos {
+		kubeconfig, err := loadKubeConfig(configInfo.FilePath)
+		if err != nil {
+			continue
+		}
+
+		fmt.Printf("📁 %s:\n", filepath.Base(configInfo.FilePath))
+
+		for _, context := range configInfo.Contexts {
+			expiries := contextCredentialExpiries(kubeconfig, context, configInfo.FilePath)
+			if len(expiries) == 0 {
+				fmt.Printf("   %s: no expiring credentials\n", context)
+				continue
+			}
+
+			for _, entry := range expiries {
+				fmt.Printf("%s %s (%s): %s\n", expiryIndicator(entry, now), context, entry.Kind, describeExpiry(entry, now))
+				if needsExpiryWarning(entry, now) {
+					expiring++
+				}
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("Legend:")
+	fmt.Println("✅ = Valid")
+	fmt.Printf("⚠️  = Expires within %d days\n", expiryWarnDays)
+	fmt.Println("❌ = Expired")
+	fmt.Println("❓ = Could not be read")
+
+	if expiring > 0 {
+		fmt.Printf("\n⚠️  %d credential(s) expired or expiring soon!\n", expiring)
+	}
+	return nil
+}
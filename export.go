@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var exportOutputFile string
+
+// inlinedFileFields maps file-referencing kubeconfig fields to the *-data
+// field their contents are embedded into when flattening.
+var inlinedFileFields = []struct {
+	pathKey string
+	dataKey string
+}{
+	{"certificate-authority", "certificate-authority-data"},
+	{"client-certificate", "client-certificate-data"},
+	{"client-key", "client-key-data"},
+}
+
+// flattenRawDetail replaces file references in a cluster or user detail with
+// their base64-encoded contents, resolving relative paths against the
+// kubeconfig file they came from.
+func flattenRawDetail(detail map[interface{}]interface{}, configFilePath string) error {
+	for _, field := range inlinedFileFields {
+		path := rawString(detail, field.pathKey)
+		if path == "" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(resolveConfigPath(configFilePath, path))
+		if err != nil {
+			return fmt.Errorf("inlining %s: %v", field.pathKey, err)
+		}
+
+		detail[field.dataKey] = base64.StdEncoding.EncodeToString(data)
+		delete(detail, field.pathKey)
+	}
+	return nil
+}
+
+// addUniqueEntry appends entry to entries under its own name, or under a
+// suffixed name if a different entry already uses it. Identical entries are
+// shared. The name the entry ended up with is returned.
+func addUniqueEntry(entries []map[interface{}]interface{}, entry map[interface{}]interface{}) ([]map[interface{}]interface{}, string) {
+	baseName := rawEntryName(entry)
+	name := baseName
+
+	for suffix := 2; ; suffix++ {
+		var existing map[interface{}]interface{}
+		for _, candidate := range entries {
+			if rawEntryName(candidate) == name {
+				existing = candidate
+				break
+			}
+		}
+
+		if existing == nil {
+			entry["name"] = name
+			return append(entries, entry), name
+		}
+
+		renamed := copyRaw(entry).(map[interface{}]interface{})
+		renamed["name"] = name
+		if reflect.DeepEqual(existing, renamed) {
+			return entries, name
+		}
+
+		name = fmt.Sprintf("%s-%d", baseName, suffix)
+	}
+}
+
+// standaloneBuilder accumulates contexts, together with the clusters and
+// users they reference, into one self-contained kubeconfig.
+type standaloneBuilder struct {
+	clusters []map[interface{}]interface{}
+	users    []map[interface{}]interface{}
+	contexts []map[interface{}]interface{}
+}
+
+// add copies contextName out of rawConfig under newName, flattening file
+// references relative to configFilePath.
+func (b *standaloneBuilder) add(rawConfig map[interface{}]interface{}, configFilePath, contextName, newName string) error {
+	fileName := filepath.Base(configFilePath)
+
+	contextEntry := findRawEntry(rawConfig, "contexts", contextName)
+	if contextEntry == nil {
+		return notFoundError("context '%s' not found in %s", contextName, fileName)
+	}
+	contextEntry = copyRaw(contextEntry).(map[interface{}]interface{})
+	contextEntry["name"] = newName
+
+	contextDetail := rawEntryDetail(contextEntry, "context")
+	if contextDetail == nil {
+		return fmt.Errorf("context '%s' in %s has no context details", contextName, fileName)
+	}
+
+	clusterName := rawString(contextDetail, "cluster")
+	clusterEntry := findRawEntry(rawConfig, "clusters", clusterName)
+	if clusterEntry == nil {
+		return notFoundError("cluster '%s' referenced by '%s' not found in %s", clusterName, contextName, fileName)
+	}
+	clusterEntry = copyRaw(clusterEntry).(map[interface{}]interface{})
+	if detail := rawEntryDetail(clusterEntry, "cluster"); detail != nil {
+		if err := flattenRawDetail(detail, configFilePath); err != nil {
+			return fmt.Errorf("cluster '%s': %v", clusterName, err)
+		}
+	}
+	b.clusters, clusterName = addUniqueEntry(b.clusters, clusterEntry)
+	contextDetail["cluster"] = clusterName
+
+	if userName := rawString(contextDetail, "user"); userName != "" {
+		userEntry := findRawEntry(rawConfig, "users", userName)
+		if userEntry == nil {
+			return notFoundError("user '%s' referenced by '%s' not found in %s", userName, contextName, fileName)
+		}
+		userEntry = copyRaw(userEntry).(map[interface{}]interface{})
+		if detail := rawEntryDetail(userEntry, "user"); detail != nil {
+			if err := flattenRawDetail(detail, configFilePath); err != nil {
+				return fmt.Errorf("user '%s': %v", userName, err)
+			}
+		}
+		b.users, userName = addUniqueEntry(b.users, userEntry)
+		contextDetail["user"] = userName
+	}
+
+	b.contexts = append(b.contexts, contextEntry)
+	return nil
+}
+
+func (b *standaloneBuilder) kubeconfig(currentContext string) yaml.MapSlice {
+	toList := func(entries []map[interface{}]interface{}) []interface{} {
+		list := make([]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			list = append(list, entry)
+		}
+		return list
+	}
+
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Config"},
+		{Key: "clusters", Value: toList(b.clusters)},
+		{Key: "contexts", Value: toList(b.contexts)},
+		{Key: "current-context", Value: currentContext},
+		{Key: "preferences", Value: map[interface{}]interface{}{}},
+		{Key: "users", Value: toList(b.users)},
+	}
+}
+
+// buildStandaloneKubeConfig collects the selected contexts, with only the
+// clusters and users they reference, into one flattened kubeconfig.
+func buildStandaloneKubeConfig(configInfos []ConfigInfo, contextNames []string) (yaml.MapSlice, error) {
+	var builder standaloneBuilder
+	seen := make(map[string]bool)
+
+	for _, contextName := range contextNames {
+		if seen[contextName] {
+			continue
+		}
+		seen[contextName] = true
+
+		filePath := findContextFile(configInfos, contextName)
+		if filePath == "" {
+			return nil, notFoundError("context '%s' not found", contextName)
+		}
+
+		rawConfig, err := loadRawKubeConfig(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %v", filepath.Base(filePath), err)
+		}
+
+		if err := builder.add(rawConfig, filePath, contextName, contextName); err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.kubeconfig(contextNames[0]), nil
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	for _, contextName := range args {
+		filePath := findContextFile(configInfos, contextName)
+		if filePath != "" && isProductionEnvironmentCombined(contextName, filePath) {
+			fmt.Fprintf(os.Stderr, "🔴 Exporting credentials of PRODUCTION context '%s' - handle the output with care!\n", contextName)
+		}
+	}
+
+	standalone, err := buildStandaloneKubeConfig(configInfos, args)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(standalone)
+	if err != nil {
+		return err
+	}
+
+	if exportOutputFile == "" || exportOutputFile == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := ioutil.WriteFile(exportOutputFile, data, 0600); err != nil {
+		return fmt.Errorf("writing %s: %v", exportOutputFile, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ Exported %d context(s) to %s\n", len(args), exportOutputFile)
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	findSearch      string
+	findTier        string
+	findConcurrency int
+	findTimeout     time.Duration
+	findRefresh     bool
+)
+
+// NamespaceHit is a namespace found by kjx find, with the context it is in.
+type NamespaceHit struct {
+	Context   string
+	Namespace string
+	Score     int
+}
+
+func (h NamespaceHit) target() string {
+	return h.Context + "/" + h.Namespace
+}
+
+// collectNamespaces gets the namespaces of every context, from the cache when
+// it is fresh and otherwise from the clusters, queried concurrently. Contexts
+// that could not be queried are returned with their errors.
+func collectNamespaces(configInfos []ConfigInfo, contextNames []string) (map[string][]string, map[string]error) {
+	namespaces := make(map[string][]string)
+	failures := make(map[string]error)
+
+	cache := loadNamespaceCache()
+	var pending []string
+	for _, name := range contextNames {
+		if cached, ok := cache.fresh(name); ok && !findRefresh {
+			namespaces[name] = cached
+		} else {
+			pending = append(pending, name)
+		}
+	}
+	if len(pending) == 0 {
+		return namespaces, failures
+	}
+
+	concurrency := findConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	fetched := make(map[string][]string)
+	for _, name := range pending {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), findTimeout)
+			defer cancel()
+			target := NamespaceTarget{Context: name, File: findContextFile(configInfos, name), Outside: true}
+			list, err := getLiveNamespacesContext(ctx, target)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if ctx.Err() == context.DeadlineExceeded {
+				failures[name] = fmt.Errorf("timed out after %s", findTimeout)
+			} else if err != nil {
+				failures[name] = err
+			} else {
+				namespaces[name] = list
+				fetched[name] = list
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(fetched) > 0 {
+		storeCachedNamespaces(fetched)
+	}
+	return namespaces, failures
+}
+
+// findNamespaceHits matches term against every namespace. An exact name
+// outranks any fuzzy match; ties keep the order of contextNames.
+func findNamespaceHits(contextNames []string, namespaces map[string][]string, term string) []NamespaceHit {
+	var hits []NamespaceHit
+	for _, contextName := range contextNames {
+		for _, namespace := range namespaces[contextName] {
+			if namespace == term {
+				hits = append(hits, NamespaceHit{Context: contextName, Namespace: namespace, Score: 1 << 20})
+				continue
+			}
+			if score, _, ok := fuzzyMatch(term, namespace); ok {
+				hits = append(hits, NamespaceHit{Context: contextName, Namespace: namespace, Score: score})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	return hits
+}
+
+func printNamespaceHits(configInfos []ConfigInfo, hits []NamespaceHit) error {
+	if outputFormat == "name" {
+		for _, hit := range hits {
+			fmt.Println(hit.target())
+		}
+		return nil
+	}
+
+	var records []NamespaceRecord
+	for _, hit := range hits {
+		records = append(records, NamespaceRecord{
+			Name:    hit.Namespace,
+			Context: hit.Context,
+			Current: hit.Context == currentContext && hit.Namespace == contextNamespace(findContextFile(configInfos, hit.Context), hit.Context),
+		})
+	}
+	return printNamespaceRecords(records)
+}
+
+func switchToHit(configInfos []ConfigInfo, hit NamespaceHit) error {
+	filePath := findContextFile(configInfos, hit.Context)
+	if isProductionEnvironmentCombined(hit.Context, filePath) {
+		showProductionWarning(hit.Context, filePath)
+	}
+	return switchToContext(hit.Context, hit.Namespace, configInfos)
+}
+
+func runFind(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(); err != nil {
+		return err
+	}
+	term := args[0]
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+	contextNames, err := selectContexts(configInfos, findSearch, findTier)
+	if err != nil {
+		return err
+	}
+	currentContext = getCurrentContext()
+
+	namespaces, failures := collectNamespaces(configInfos, contextNames)
+	if len(failures) > 0 {
+		var failed []string
+		for name := range failures {
+			failed = append(failed, name)
+		}
+		sort.Strings(failed)
+		for _, name := range failed {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping '%s': %v\n", name, failures[name])
+		}
+	}
+	if len(namespaces) == 0 {
+		return unreachableError("could not list namespaces in any of %d context(s)", len(contextNames))
+	}
+
+	hits := findNamespaceHits(contextNames, namespaces, term)
+	if outputFormat != "" {
+		return printNamespaceHits(configInfos, hits)
+	}
+	if len(hits) == 0 {
+		return notFoundError("no namespaces matching '%s' in %d context(s)", term, len(namespaces))
+	}
+
+	if len(hits) == 1 {
+		fmt.Printf("Found '%s'. Switching...\n", hits[0].target())
+		switchEntryPoint = entrySearch
+		return switchToHit(configInfos, hits[0])
+	}
+
+	if !stdinIsTerminal() {
+		fmt.Printf("Namespaces matching '%s':\n", term)
+		for i, hit := range hits {
+			fmt.Printf("%d) %s\n", i+1, hit.target())
+		}
+		return ambiguousError("%d namespaces match '%s'; refine the search term", len(hits), term)
+	}
+
+	items := make([]PickerItem, len(hits))
+	for i, hit := range hits {
+		label := hit.target()
+		if isProductionEnvironmentCombined(hit.Context, findContextFile(configInfos, hit.Context)) {
+			label += " 🔴"
+		}
+		items[i] = PickerItem{Label: label, Key: hit.Context}
+	}
+	picked, err := pick(PickRequest{
+		Label:   fmt.Sprintf("Namespaces matching '%s'", term),
+		Items:   items,
+		Size:    15,
+		Preview: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	switchEntryPoint = entryPicker
+	return switchToHit(configInfos, hits[picked.Index])
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Fuzzy scoring weights. Every matched character earns fuzzyScoreMatch; the
+// bonuses reward matches that start a word or continue the previous match,
+// and gaps between matched characters cost points so tight matches win.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusCamelCase   = 7
+	fuzzyBonusConsecutive = 6
+	fuzzyBonusFirstChar   = 2
+	fuzzyPenaltyGapStart  = 3
+	fuzzyPenaltyGapExtend = 1
+)
+
+var (
+	fuzzyHighlight = promptui.Styler(promptui.FGCyan, promptui.FGBold)
+	fuzzyHelpStyle = promptui.Styler(promptui.FGFaint)
+)
+
+// FuzzyMatch is one candidate that matched a fuzzy pattern. Positions are rune
+// indexes of the matched characters, for highlighting.
+type FuzzyMatch struct {
+	Index     int
+	Text      string
+	Score     int
+	Positions []int
+}
+
+func isFuzzySeparator(r rune) bool {
+	return strings.ContainsRune("-_./:@ ()[]", r)
+}
+
+// fuzzyCharBonus is the bonus for matching the character at position i,
+// depending on what comes before it.
+func fuzzyCharBonus(text []rune, i int) int {
+	if i == 0 || isFuzzySeparator(text[i-1]) {
+		return fuzzyBonusBoundary
+	}
+	if unicode.IsUpper(text[i]) && unicode.IsLower(text[i-1]) {
+		return fuzzyBonusCamelCase
+	}
+	if unicode.IsDigit(text[i]) && !unicode.IsDigit(text[i-1]) {
+		return fuzzyBonusCamelCase
+	}
+	return 0
+}
+
+// normalizeFuzzyPattern lowercases the pattern and drops spaces, so "prod pay"
+// and "prodpay" search the same way.
+func normalizeFuzzyPattern(pattern string) []rune {
+	return []rune(strings.ToLower(strings.Replace(pattern, " ", "", -1)))
+}
+
+// fuzzyMatch reports whether every pattern character occurs in text in order
+// (case-insensitive) and scores the best such alignment.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	needle := normalizeFuzzyPattern(pattern)
+	if len(needle) == 0 {
+		return 0, nil, true
+	}
+
+	haystack := []rune(text)
+	if len(needle) > len(haystack) {
+		return 0, nil, false
+	}
+
+	// best[i][j] is the best score for matching needle[:i+1] with needle[i]
+	// at haystack[j]; from[i][j] remembers where needle[i-1] was matched.
+	const unmatched = -1 << 30
+	best := make([][]int, len(needle))
+	from := make([][]int, len(needle))
+	for i := range needle {
+		best[i] = make([]int, len(haystack))
+		from[i] = make([]int, len(haystack))
+		for j := range haystack {
+			best[i][j] = unmatched
+			if unicode.ToLower(haystack[j]) != needle[i] {
+				continue
+			}
+
+			charScore := fuzzyScoreMatch + fuzzyCharBonus(haystack, j)
+			if i == 0 {
+				best[i][j] = charScore + fuzzyCharBonus(haystack, j)*(fuzzyBonusFirstChar-1)
+				continue
+			}
+
+			for k := i - 1; k < j; k++ {
+				if best[i-1][k] == unmatched {
+					continue
+				}
+				score := best[i-1][k] + charScore
+				if k == j-1 {
+					score += fuzzyBonusConsecutive
+				} else {
+					score -= fuzzyPenaltyGapStart + fuzzyPenaltyGapExtend*(j-k-2)
+				}
+				if score > best[i][j] {
+					best[i][j] = score
+					from[i][j] = k
+				}
+			}
+		}
+	}
+
+	last := len(needle) - 1
+	end := -1
+	for j := range haystack {
+		if best[last][j] != unmatched && (end < 0 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions := make([]int, len(needle))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return best[last][end], positions, true
+}
+
+// rankFuzzyMatches returns the candidates matching pattern, best score first.
+// Equal scores prefer the shorter candidate, then alphabetical order. An empty
+// pattern keeps the candidates in their original order.
+func rankFuzzyMatches(candidates []string, pattern string) []FuzzyMatch {
+	var matches []FuzzyMatch
+	if len(normalizeFuzzyPattern(pattern)) == 0 {
+		for i, candidate := range candidates {
+			matches = append(matches, FuzzyMatch{Index: i, Text: candidate})
+		}
+		return matches
+	}
+
+	for i, candidate := range candidates {
+		if score, positions, ok := fuzzyMatch(pattern, candidate); ok {
+			matches = append(matches, FuzzyMatch{Index: i, Text: candidate, Score: score, Positions: positions})
+		}
+	}
+
+	sortFuzzyMatches(matches)
+	return matches
+}
+
+// boostFuzzyMatches adds boosts[match.Index] to each score and re-ranks, so
+// callers can favour candidates for reasons other than the text.
+func boostFuzzyMatches(matches []FuzzyMatch, boosts []int) {
+	for i := range matches {
+		matches[i].Score += boosts[matches[i].Index]
+	}
+	sortFuzzyMatches(matches)
+}
+
+func sortFuzzyMatches(matches []FuzzyMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].Text) != len(matches[j].Text) {
+			return len(matches[i].Text) < len(matches[j].Text)
+		}
+		return matches[i].Text < matches[j].Text
+	})
+}
+
+// fuzzyFilter returns the matching candidates ordered by score.
+func fuzzyFilter(candidates []string, pattern string) []string {
+	var names []string
+	for _, match := range rankFuzzyMatches(candidates, pattern) {
+		names = append(names, match.Text)
+	}
+	return names
+}
+
+func highlightPositions(text string, positions []int) string {
+	if len(positions) == 0 {
+		return text
+	}
+
+	marked := make(map[int]bool, len(positions))
+	for _, position := range positions {
+		marked[position] = true
+	}
+
+	var builder strings.Builder
+	for i, r := range []rune(text) {
+		if marked[i] {
+			builder.WriteString(fuzzyHighlight(string(r)))
+		} else {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// fuzzyPickerRow is one visible row of a fuzzy picker. promptui's list can only
+// filter, not reorder, so the picker rewrites its rows in score order on every
+// keystroke and reports which row matched.
+type fuzzyPickerRow struct {
+	Label       string
+	Highlighted string
+	Details     string
+	index       int
+	matched     bool
+}
+
+func (r *fuzzyPickerRow) String() string {
+	return r.Label
+}
+
+// pickerKeyReader turns the picker's extra key bindings into Enter, so
+// promptui finishes with the highlighted item, and remembers which key it was.
+// Bytes read after a bound key are kept for the next Read, so keys typed
+// ahead reach the next prompt.
+type pickerKeyReader struct {
+	io.ReadCloser
+	mu      sync.Mutex
+	keys    map[byte]string
+	pressed string
+	pending []byte
+}
+
+// pickerInput is shared by all pickers so that bytes held back by one are
+// read by the next.
+var pickerInput = &pickerKeyReader{ReadCloser: os.Stdin}
+
+// reset binds keys for the next picker and forgets the last pressed key.
+func (r *pickerKeyReader) reset(keys map[byte]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys, r.pressed = keys, ""
+}
+
+func (r *pickerKeyReader) pressedKey() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pressed
+}
+
+func (r *pickerKeyReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.mu.Unlock()
+
+	var err error
+	if n == 0 {
+		n, err = r.ReadCloser.Read(p)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < n; i++ {
+		if name, ok := r.keys[p[i]]; ok {
+			r.pressed = name
+			p[i] = '\r'
+			r.pending = append(append([]byte{}, p[i+1:n]...), r.pending...)
+			return i + 1, err
+		}
+	}
+	return n, err
+}
+
+// controlKeyByte maps "ctrl-a" .. "ctrl-z" to the byte a terminal sends.
+func controlKeyByte(name string) (byte, bool) {
+	if len(name) != len("ctrl-a") || !strings.HasPrefix(name, "ctrl-") {
+		return 0, false
+	}
+	letter := name[len(name)-1]
+	if letter < 'a' || letter > 'z' {
+		return 0, false
+	}
+	return letter - 'a' + 1, true
+}
+
+// fuzzySelect shows the items in a promptui picker with fuzzy, ranked
+// filtering, highlighted matches and a details pane for the active item.
+func fuzzySelect(request PickRequest) (PickResult, error) {
+	labels := pickerDisplayLabels(request.Items)
+	boosts := pickerBoosts(request.Items)
+	rows := make([]*fuzzyPickerRow, len(labels))
+	for i, text := range labels {
+		rows[i] = &fuzzyPickerRow{Label: text, Highlighted: text, Details: request.Items[i].Details, index: i, matched: true}
+	}
+
+	lastInput := ""
+	rank := func(input string) {
+		lastInput = input
+		matches := rankFuzzyMatches(labels, input)
+		if len(normalizeFuzzyPattern(input)) > 0 {
+			boostFuzzyMatches(matches, boosts)
+		}
+
+		matched := make(map[int]bool)
+		order := make([]FuzzyMatch, 0, len(labels))
+		for _, match := range matches {
+			matched[match.Index] = true
+			order = append(order, match)
+		}
+		for i, text := range labels {
+			if !matched[i] {
+				order = append(order, FuzzyMatch{Index: i, Text: text})
+			}
+		}
+		for i, match := range order {
+			rows[i].Label = match.Text
+			rows[i].Highlighted = highlightPositions(match.Text, match.Positions)
+			rows[i].Details = request.Items[match.Index].Details
+			rows[i].index = match.Index
+			rows[i].matched = matched[match.Index]
+		}
+	}
+
+	help := `{{ "Use the arrow keys to navigate:" | faint }} {{ .NextKey | faint }} {{ .PrevKey | faint }} ` +
+		`{{ .PageDownKey | faint }} {{ .PageUpKey | faint }}` +
+		`{{ if .Search }} {{ "and" | faint }} {{ .SearchKey | faint }} {{ "toggles search" | faint }}{{ end }}`
+
+	keys := make(map[byte]string)
+	for _, key := range request.Keys {
+		if code, ok := controlKeyByte(key.Name); ok {
+			keys[code] = key.Name
+		}
+	}
+	pickerInput.reset(keys)
+	if len(request.Keys) > 0 {
+		help += "\n" + fuzzyHelpStyle(describePickerKeys(request.Keys))
+	}
+
+	details := ""
+	for _, item := range request.Items {
+		if item.Details != "" {
+			details = "{{ .Details }}"
+			break
+		}
+	}
+
+	prompt := promptui.Select{
+		Label:             request.Label,
+		Items:             rows,
+		Size:              request.Size,
+		StartInSearchMode: request.StartInSearch,
+		Stdin:             pickerInput,
+		Templates: &promptui.SelectTemplates{
+			Label:    promptui.IconInitial + " {{ . }}: ",
+			Active:   promptui.IconSelect + " {{ .Highlighted }}",
+			Inactive: "  {{ .Highlighted }}",
+			Selected: promptui.IconGood + " {{ .Label | faint }}",
+			Details:  details,
+			Help:     help,
+		},
+		Searcher: func(input string, index int) bool {
+			if index == 0 || input != lastInput {
+				rank(input)
+			}
+			return rows[index].matched
+		},
+	}
+
+	index, _, err := prompt.Run()
+	if err != nil {
+		return PickResult{}, promptError(err)
+	}
+	return PickResult{Index: rows[index].index, Key: pickerInput.pressedKey()}, nil
+}
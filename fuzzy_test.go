@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		match   bool
+	}{
+		{"subsequence", "ppm", "prod-payments", true},
+		{"spaces ignored", "prod pay", "prod-payments", true},
+		{"empty pattern", "", "prod-payments", true},
+		{"upper-case pattern", "PROD", "prod-eu", true},
+		{"upper-case text", "prod", "Prod-EU", true},
+		{"out of order", "dp", "prod", false},
+		{"missing character", "prodx", "prod-eu", false},
+		{"pattern longer than text", "staging", "stag", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, ok := fuzzyMatch(test.pattern, test.text); ok != test.match {
+				t.Errorf("fuzzyMatch(%q, %q) matched = %v, want %v", test.pattern, test.text, ok, test.match)
+			}
+		})
+	}
+}
+
+func TestFuzzyFilterOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		candidates []string
+		want       []string
+	}{
+		{
+			name:       "word boundary bonus",
+			pattern:    "pay",
+			candidates: []string{"eupayments", "eu-payments"},
+			want:       []string{"eu-payments", "eupayments"},
+		},
+		{
+			name:       "camel case counts as a boundary",
+			pattern:    "pay",
+			candidates: []string{"eupayments", "euPayments"},
+			want:       []string{"euPayments", "eupayments"},
+		},
+		{
+			name:       "consecutive run bonus",
+			pattern:    "abc",
+			candidates: []string{"zazbzc", "zabczz"},
+			want:       []string{"zabczz", "zazbzc"},
+		},
+		{
+			name:       "shorter match preferred",
+			pattern:    "api",
+			candidates: []string{"apis", "api"},
+			want:       []string{"api", "apis"},
+		},
+		{
+			name:       "non-matching candidates dropped",
+			pattern:    "prod",
+			candidates: []string{"dev", "prod-eu", "staging"},
+			want:       []string{"prod-eu"},
+		},
+		{
+			name:       "case-insensitive",
+			pattern:    "PAY",
+			candidates: []string{"dev", "prod-Payments"},
+			want:       []string{"prod-Payments"},
+		},
+		{
+			name:       "empty pattern keeps order",
+			pattern:    "",
+			candidates: []string{"staging", "dev", "prod"},
+			want:       []string{"staging", "dev", "prod"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := fuzzyFilter(test.candidates, test.pattern); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("fuzzyFilter(%q, %q) = %q, want %q", test.candidates, test.pattern, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPickerKeyReaderKeepsTypedAhead(t *testing.T) {
+	reader := &pickerKeyReader{ReadCloser: ioutil.NopCloser(strings.NewReader("ab\x14cd"))}
+	reader.reset(map[byte]string{0x14: "ctrl-t"})
+
+	buf := make([]byte, 16)
+	n, err := reader.Read(buf)
+	if err != nil || string(buf[:n]) != "ab\r" {
+		t.Fatalf("first read = %q, %v; want %q", buf[:n], err, "ab\r")
+	}
+	if key := reader.pressedKey(); key != "ctrl-t" {
+		t.Errorf("pressed = %q, want ctrl-t", key)
+	}
+
+	n, err = reader.Read(buf)
+	if err != nil || string(buf[:n]) != "cd" {
+		t.Errorf("second read = %q, %v; want %q", buf[:n], err, "cd")
+	}
+}
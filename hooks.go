@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const defaultHookTimeout = time.Minute
+
+// HookCommands are shell commands run before and after a switch.
+type HookCommands struct {
+	Pre  []string `yaml:"pre,omitempty"`
+	Post []string `yaml:"post,omitempty"`
+}
+
+// HookSettings configures switch hooks in config.yaml. The global hooks run
+// first, then those for the tier, for matching tags ("key=value" or "key")
+// and for the context itself.
+type HookSettings struct {
+	HookCommands `yaml:",inline"`
+	Timeout      string                  `yaml:"timeout,omitempty"`
+	Tiers        map[string]HookCommands `yaml:"tiers,omitempty"`
+	Tags         map[string]HookCommands `yaml:"tags,omitempty"`
+	Contexts     map[string]HookCommands `yaml:"contexts,omitempty"`
+}
+
+// HookTarget is what a switch is about to point kubectl at.
+type HookTarget struct {
+	Action    string
+	Context   string
+	Namespace string
+	File      string
+	Tier      string
+}
+
+func newHookTarget(action, contextName, namespace, filePath string) HookTarget {
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		filePath = absPath
+	}
+	return HookTarget{
+		Action:    action,
+		Context:   contextName,
+		Namespace: namespace,
+		File:      filePath,
+		Tier:      environmentTier(contextName, filePath),
+	}
+}
+
+// switchContextTags returns the tags of a context read from its file and the
+// metadata sidecar.
+func switchContextTags(filePath, contextName string) map[string]string {
+	kubeconfig, err := loadKubeConfig(filePath)
+	if err != nil {
+		return nil
+	}
+	detail, _ := findContextDetail(kubeconfig, contextName)
+	metadata, err := loadMetadataFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return mergedContextTags(detail, contextName, metadata)
+}
+
+// hooksFor collects the pre or post hooks that apply to target.
+func (h HookSettings) hooksFor(phase string, target HookTarget) []string {
+	pick := func(commands HookCommands) []string {
+		if phase == "pre" {
+			return commands.Pre
+		}
+		return commands.Post
+	}
+
+	hooks := append([]string{}, pick(h.HookCommands)...)
+	hooks = append(hooks, pick(h.Tiers[target.Tier])...)
+	if len(h.Tags) > 0 {
+		tags := switchContextTags(target.File, target.Context)
+		for _, tag := range sortedHookTags(h.Tags) {
+			filters, err := parseTagFilters([]string{tag})
+			if err == nil && matchesTags(tags, filters) {
+				hooks = append(hooks, pick(h.Tags[tag])...)
+			}
+		}
+	}
+	return append(hooks, pick(h.Contexts[target.Context])...)
+}
+
+func sortedHookTags(tags map[string]HookCommands) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hookTimeout(h HookSettings) time.Duration {
+	if h.Timeout == "" {
+		return defaultHookTimeout
+	}
+	timeout, err := time.ParseDuration(h.Timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid hooks timeout '%s', using %s\n", h.Timeout, defaultHookTimeout)
+		return defaultHookTimeout
+	}
+	return timeout
+}
+
+func runHook(command string, phase string, target HookTarget, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hook := exec.CommandContext(ctx, "sh", "-c", command)
+	env := setEnv(os.Environ(), "KJX_HOOK", phase)
+	env = setEnv(env, "KJX_ACTION", target.Action)
+	env = setEnv(env, "KJX_CONTEXT", target.Context)
+	env = setEnv(env, "KJX_NAMESPACE", target.Namespace)
+	env = setEnv(env, "KJX_FILE", target.File)
+	env = setEnv(env, "KJX_TIER", target.Tier)
+	hook.Env = env
+	// Hooks may need to ask for input (SSO logins); their output goes to
+	// stderr so that kjx's own stdout stays clean.
+	hook.Stdin = os.Stdin
+	hook.Stdout = os.Stderr
+	hook.Stderr = os.Stderr
+	hook.WaitDelay = time.Second
+
+	err := hook.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s-switch hook '%s' timed out after %s", phase, command, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s-switch hook '%s' failed: %v", phase, command, err)
+	}
+	return nil
+}
+
+// runSwitchHooks runs the hooks of one phase in order. A failing pre hook
+// stops the switch; failing post hooks are reported but the switch stands.
+func runSwitchHooks(phase string, target HookTarget) error {
+	settings, err := loadSettings()
+	if err != nil {
+		// Without the settings kjx cannot tell which pre hooks are required.
+		if phase == "pre" {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+
+	hooks := settings.Hooks.hooksFor(phase, target)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	timeout := hookTimeout(settings.Hooks)
+	for _, command := range hooks {
+		if err := runHook(command, phase, target, timeout); err != nil {
+			if phase == "pre" {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	importSplitBy string
+	importForce   bool
+	importRename  bool
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// importGroup is one output file of an import: the contexts that go into it.
+type importGroup struct {
+	name     string
+	contexts []string
+}
+
+func importFileName(groupName string) string {
+	return unsafeFileNameChars.ReplaceAllString(groupName, "-") + ".yaml"
+}
+
+func groupImportedContexts(rawConfig map[interface{}]interface{}, splitBy string) []importGroup {
+	var groups []importGroup
+	index := make(map[string]int)
+
+	for _, entry := range rawNamedEntries(rawConfig, "contexts") {
+		contextName := rawEntryName(entry)
+		if contextName == "" {
+			continue
+		}
+
+		groupName := contextName
+		if splitBy == "cluster" {
+			groupName = rawString(rawEntryDetail(entry, "context"), "cluster")
+		}
+
+		if i, ok := index[groupName]; ok {
+			groups[i].contexts = append(groups[i].contexts, contextName)
+			continue
+		}
+		index[groupName] = len(groups)
+		groups = append(groups, importGroup{name: groupName, contexts: []string{contextName}})
+	}
+
+	return groups
+}
+
+// sameContextTarget reports whether two single-context builders point at the
+// same cluster with the same credentials and namespace, ignoring entry names.
+func sameContextTarget(a, b *standaloneBuilder) bool {
+	if len(a.contexts) != 1 || len(b.contexts) != 1 || len(a.clusters) != 1 || len(b.clusters) != 1 {
+		return false
+	}
+
+	if !reflect.DeepEqual(rawEntryDetail(a.clusters[0], "cluster"), rawEntryDetail(b.clusters[0], "cluster")) {
+		return false
+	}
+
+	var userA, userB map[interface{}]interface{}
+	if len(a.users) == 1 {
+		userA = rawEntryDetail(a.users[0], "user")
+	}
+	if len(b.users) == 1 {
+		userB = rawEntryDetail(b.users[0], "user")
+	}
+	if !reflect.DeepEqual(userA, userB) {
+		return false
+	}
+
+	return rawString(rawEntryDetail(a.contexts[0], "context"), "namespace") ==
+		rawString(rawEntryDetail(b.contexts[0], "context"), "namespace")
+}
+
+func isAlreadyImported(configInfos []ConfigInfo, rawConfig map[interface{}]interface{}, sourcePath, contextName string) (bool, error) {
+	existingFile := findContextFile(configInfos, contextName)
+	if existingFile == "" {
+		return false, nil
+	}
+
+	var incoming standaloneBuilder
+	if err := incoming.add(rawConfig, sourcePath, contextName, contextName); err != nil {
+		return false, err
+	}
+
+	existingConfig, err := loadRawKubeConfig(existingFile)
+	if err != nil {
+		return false, err
+	}
+	var existing standaloneBuilder
+	if err := existing.add(existingConfig, existingFile, contextName, contextName); err != nil {
+		return false, nil
+	}
+
+	return sameContextTarget(&incoming, &existing), nil
+}
+
+// sameRawEntry reports whether existing, a clusters or users entry of
+// existingPath, has the same name and content as the flattened incoming entry.
+func sameRawEntry(existing map[interface{}]interface{}, existingPath string, incoming map[interface{}]interface{}, detailKey string) bool {
+	if existing == nil || rawEntryName(existing) != rawEntryName(incoming) {
+		return false
+	}
+	existing = copyRaw(existing).(map[interface{}]interface{})
+	if detail := rawEntryDetail(existing, detailKey); detail != nil {
+		if err := flattenRawDetail(detail, existingPath); err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(existing, incoming)
+}
+
+// findImportHost looks for an existing file that already holds the cluster
+// of an incoming context, preferring one that also holds its user, so the
+// context can be added there instead of duplicating those entries in a new
+// file.
+func findImportHost(configInfos []ConfigInfo, incoming *standaloneBuilder) string {
+	host := ""
+	cluster := incoming.clusters[0]
+	for _, configInfo := range configInfos {
+		rawConfig, err := loadRawKubeConfig(configInfo.FilePath)
+		if err != nil {
+			continue
+		}
+		if !sameRawEntry(findRawEntry(rawConfig, "clusters", rawEntryName(cluster)), configInfo.FilePath, cluster, "cluster") {
+			continue
+		}
+		if len(incoming.users) == 0 {
+			return configInfo.FilePath
+		}
+		user := incoming.users[0]
+		if sameRawEntry(findRawEntry(rawConfig, "users", rawEntryName(user)), configInfo.FilePath, user, "user") {
+			return configInfo.FilePath
+		}
+		if host == "" {
+			host = configInfo.FilePath
+		}
+	}
+	return host
+}
+
+// importPlan holds every kubeconfig an import writes. Nothing is written
+// until all contexts have been checked, so a refused conflict leaves the
+// config directory as it was.
+type importPlan struct {
+	order    []string
+	configs  map[string]map[interface{}]interface{}
+	created  map[string]bool
+	imported map[string][]string
+	reused   map[string][]string
+}
+
+func newImportPlan() *importPlan {
+	return &importPlan{
+		configs:  make(map[string]map[interface{}]interface{}),
+		created:  make(map[string]bool),
+		imported: make(map[string][]string),
+		reused:   make(map[string][]string),
+	}
+}
+
+// config returns the planned content of filePath, starting from the file on
+// disk or from an empty kubeconfig for a new file.
+func (p *importPlan) config(filePath string) (map[interface{}]interface{}, error) {
+	if rawConfig, ok := p.configs[filePath]; ok {
+		return rawConfig, nil
+	}
+
+	rawConfig := newRawKubeConfig()
+	if _, err := os.Stat(filePath); err == nil {
+		if rawConfig, err = loadRawKubeConfig(filePath); err != nil {
+			return nil, fmt.Errorf("loading %s: %v", filepath.Base(filePath), err)
+		}
+	} else {
+		p.created[filePath] = true
+	}
+	p.configs[filePath] = rawConfig
+	p.order = append(p.order, filePath)
+	return rawConfig, nil
+}
+
+// mergeImportEntry adds a flattened clusters or users entry to rawConfig. An
+// entry with the same name and content is reused; one with different content
+// is replaced with --force and otherwise kept, the new entry getting a
+// suffixed name. It returns the name the context has to reference.
+func mergeImportEntry(rawConfig map[interface{}]interface{}, filePath, key, detailKey string, entry map[interface{}]interface{}) (string, bool) {
+	entries := rawNamedEntries(rawConfig, key)
+	name := rawEntryName(entry)
+	for i, existing := range entries {
+		if rawEntryName(existing) != name {
+			continue
+		}
+		if sameRawEntry(existing, filePath, entry, detailKey) {
+			return name, true
+		}
+		if importForce {
+			entries[i] = entry
+			setRawNamedEntries(rawConfig, key, entries)
+			return name, false
+		}
+		break
+	}
+
+	entries, name = addUniqueEntry(entries, entry)
+	setRawNamedEntries(rawConfig, key, entries)
+	return name, false
+}
+
+// add puts the single context of incoming into filePath under newName,
+// replacing a context of that name already in the file.
+func (p *importPlan) add(filePath string, incoming *standaloneBuilder, newName string) error {
+	rawConfig, err := p.config(filePath)
+	if err != nil {
+		return err
+	}
+
+	contextEntry := incoming.contexts[0]
+	contextEntry["name"] = newName
+	contextDetail := rawEntryDetail(contextEntry, "context")
+
+	clusterName, reused := mergeImportEntry(rawConfig, filePath, "clusters", "cluster", incoming.clusters[0])
+	contextDetail["cluster"] = clusterName
+	if reused {
+		p.reused[filePath] = append(p.reused[filePath], fmt.Sprintf("cluster '%s'", clusterName))
+	}
+	if len(incoming.users) > 0 {
+		userName, reused := mergeImportEntry(rawConfig, filePath, "users", "user", incoming.users[0])
+		contextDetail["user"] = userName
+		if reused {
+			p.reused[filePath] = append(p.reused[filePath], fmt.Sprintf("user '%s'", userName))
+		}
+	}
+
+	contexts := rawNamedEntries(rawConfig, "contexts")
+	replaced := false
+	for i, existing := range contexts {
+		if rawEntryName(existing) == newName {
+			contexts[i], replaced = contextEntry, true
+			break
+		}
+	}
+	if !replaced {
+		contexts = append(contexts, contextEntry)
+	}
+	setRawNamedEntries(rawConfig, "contexts", contexts)
+	if p.created[filePath] && rawString(rawConfig, "current-context") == "" {
+		rawConfig["current-context"] = newName
+	}
+
+	p.imported[filePath] = append(p.imported[filePath], newName)
+	return nil
+}
+
+// write saves every planned file and reports what went where.
+func (p *importPlan) write() (int, error) {
+	imported := 0
+	for _, filePath := range p.order {
+		if len(p.imported[filePath]) == 0 {
+			continue
+		}
+		if err := saveRawKubeConfig(filePath, p.configs[filePath], 0600); err != nil {
+			return imported, fmt.Errorf("writing %s: %v", filePath, err)
+		}
+
+		prodIndicator := ""
+		if isProductionConfigFile(filePath) || isProductionEnvironment(p.imported[filePath][0]) {
+			prodIndicator = " 🔴"
+		}
+		if p.created[filePath] {
+			fmt.Printf("✅ Imported %d context(s) into %s%s\n", len(p.imported[filePath]), filepath.Base(filePath), prodIndicator)
+		} else {
+			fmt.Printf("✅ Added %d context(s) to %s%s\n", len(p.imported[filePath]), filepath.Base(filePath), prodIndicator)
+		}
+		if reused := p.reused[filePath]; len(reused) > 0 {
+			fmt.Printf("   ♻️  Reused %s\n", strings.Join(uniqueStrings(reused), ", "))
+		}
+		imported += len(p.imported[filePath])
+	}
+	return imported, nil
+}
+
+func uniqueStrings(list []string) []string {
+	var unique []string
+	for _, item := range list {
+		if !containsString(unique, item) {
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+func suggestFreeName(name string, taken func(string) bool) string {
+	for suffix := 2; ; suffix++ {
+		candidate := fmt.Sprintf("%s-%d", name, suffix)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// resolveImportConflict picks a new name for something that already exists,
+// either automatically (--rename) or by asking on an interactive terminal.
+func resolveImportConflict(kind, name string, taken func(string) bool, interactive bool) (string, error) {
+	suggestion := suggestFreeName(name, taken)
+	if importRename {
+		return suggestion, nil
+	}
+	if !interactive {
+		return "", refusedError("%s '%s' already exists; use --rename to import under a new name", kind, name)
+	}
+
+	prompt := promptui.Prompt{
+		Label:   fmt.Sprintf("%s '%s' already exists, import as", kind, name),
+		Default: suggestion,
+		Validate: func(input string) error {
+			if input == "" {
+				return fmt.Errorf("name must not be empty")
+			}
+			if taken(input) {
+				return fmt.Errorf("'%s' is taken too", input)
+			}
+			return nil
+		},
+	}
+
+	result, err := prompt.Run()
+	if err != nil {
+		return "", promptError(err)
+	}
+	return result, nil
+}
+
+func readImportSource(source string) ([]byte, string, error) {
+	if source == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, "", err
+		}
+		cwd, _ := os.Getwd()
+		return data, filepath.Join(cwd, "stdin"), nil
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, "", err
+	}
+	absPath, err := filepath.Abs(source)
+	if err != nil {
+		absPath = source
+	}
+	return data, absPath, nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if importSplitBy != "context" && importSplitBy != "cluster" {
+		return fmt.Errorf("unknown --split value '%s' (expected context or cluster)", importSplitBy)
+	}
+
+	data, sourcePath, err := readImportSource(args[0])
+	if err != nil {
+		return notFoundError("reading %s: %v", args[0], err)
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("parsing %s: %v", args[0], err)
+	}
+	rawConfig, ok := parsed.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not a kubeconfig", args[0])
+	}
+
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return fmt.Errorf("creating %s: %v", configDir, err)
+	}
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	groups := groupImportedContexts(rawConfig, importSplitBy)
+	if len(groups) == 0 {
+		return notFoundError("no contexts found in %s", args[0])
+	}
+
+	interactive := args[0] != "-" && stdinIsTerminal()
+	plan := newImportPlan()
+	usedNames := make(map[string]bool)
+	contextTaken := func(name string) bool {
+		return usedNames[name] || findContextFile(configInfos, name) != ""
+	}
+	fileTaken := func(name string) bool {
+		filePath := filepath.Join(configDir, importFileName(name))
+		if _, planned := plan.configs[filePath]; planned {
+			return true
+		}
+		_, err := os.Stat(filePath)
+		return err == nil
+	}
+
+	skipped := 0
+	for _, group := range groups {
+		// The group's own file is only settled once a context needs it.
+		groupPath := ""
+		groupTarget := func() (string, error) {
+			if groupPath != "" {
+				return groupPath, nil
+			}
+			groupPath = filepath.Join(configDir, importFileName(group.name))
+			if _, planned := plan.configs[groupPath]; !planned && fileTaken(group.name) && !importForce {
+				if !importRename && !interactive {
+					return "", refusedError("%s already exists in %s; use --force to replace conflicting entries or --rename to write a new file", filepath.Base(groupPath), configDir)
+				}
+				newGroupName, err := resolveImportConflict("file", group.name, fileTaken, interactive)
+				if err != nil {
+					return "", err
+				}
+				groupPath = filepath.Join(configDir, importFileName(newGroupName))
+			}
+			return groupPath, nil
+		}
+
+		for _, contextName := range group.contexts {
+			duplicate, err := isAlreadyImported(configInfos, rawConfig, sourcePath, contextName)
+			if err != nil {
+				return err
+			}
+			if duplicate {
+				fmt.Printf("⏭️  Context '%s' is already present, skipping\n", contextName)
+				skipped++
+				continue
+			}
+
+			var incoming standaloneBuilder
+			if err := incoming.add(rawConfig, sourcePath, contextName, contextName); err != nil {
+				return err
+			}
+
+			targetPath := findImportHost(configInfos, &incoming)
+			if targetPath == "" {
+				if targetPath, err = groupTarget(); err != nil {
+					return err
+				}
+			}
+
+			// With --force a context of the same name in the target file is
+			// replaced, so it is not a conflict.
+			existingFile := findContextFile(configInfos, contextName)
+			replacedByForce := importForce && !usedNames[contextName] && existingFile != "" && sameFilePath(existingFile, targetPath)
+			newName := contextName
+			if contextTaken(contextName) && !replacedByForce {
+				newName, err = resolveImportConflict("context", contextName, contextTaken, interactive)
+				if err != nil {
+					return err
+				}
+			}
+			usedNames[newName] = true
+
+			if err := plan.add(targetPath, &incoming, newName); err != nil {
+				return err
+			}
+		}
+	}
+
+	imported, err := plan.write()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n📥 %d context(s) imported, %d already present\n", imported, skipped)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,20 +9,38 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v2"
 )
 
+// KubeConfig models the complete v1 kubeconfig schema. Every struct keeps
+// keys it does not know about in Extra, so loading and saving a file through
+// these types never drops data.
 type KubeConfig struct {
 	APIVersion     string                 `yaml:"apiVersion"`
 	Kind           string                 `yaml:"kind"`
+	Preferences    Preferences            `yaml:"preferences"`
 	Clusters       []Cluster              `yaml:"clusters"`
 	Contexts       []Context              `yaml:"contexts"`
 	CurrentContext string                 `yaml:"current-context"`
 	Users          []User                 `yaml:"users"`
-	Preferences    map[string]interface{} `yaml:"preferences,omitempty"`
+	Extensions     []NamedExtension       `yaml:"extensions,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+type Preferences struct {
+	Colors     bool                   `yaml:"colors,omitempty"`
+	Extensions []NamedExtension       `yaml:"extensions,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+type NamedExtension struct {
+	Name      string      `yaml:"name"`
+	Extension interface{} `yaml:"extension"`
 }
 
 type Context struct {
@@ -30,9 +49,11 @@ type Context struct {
 }
 
 type ContextDetail struct {
-	Cluster   string `yaml:"cluster"`
-	User      string `yaml:"user"`
-	Namespace string `yaml:"namespace,omitempty"`
+	Cluster    string                 `yaml:"cluster"`
+	User       string                 `yaml:"user"`
+	Namespace  string                 `yaml:"namespace,omitempty"`
+	Extensions []NamedExtension       `yaml:"extensions,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
 }
 
 type Cluster struct {
@@ -41,10 +62,15 @@ type Cluster struct {
 }
 
 type ClusterDetail struct {
-	CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
-	CertificateAuthority     string `yaml:"certificate-authority,omitempty"`
-	Server                   string `yaml:"server"`
-	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthorityData string                 `yaml:"certificate-authority-data,omitempty"`
+	CertificateAuthority     string                 `yaml:"certificate-authority,omitempty"`
+	Server                   string                 `yaml:"server"`
+	TLSServerName            string                 `yaml:"tls-server-name,omitempty"`
+	InsecureSkipTLSVerify    bool                   `yaml:"insecure-skip-tls-verify,omitempty"`
+	ProxyURL                 string                 `yaml:"proxy-url,omitempty"`
+	DisableCompression       bool                   `yaml:"disable-compression,omitempty"`
+	Extensions               []NamedExtension       `yaml:"extensions,omitempty"`
+	Extra                    map[string]interface{} `yaml:",inline"`
 }
 
 type User struct {
@@ -58,26 +84,63 @@ type UserDetail struct {
 	ClientCertificate     string                 `yaml:"client-certificate,omitempty"`
 	ClientKey             string                 `yaml:"client-key,omitempty"`
 	Token                 string                 `yaml:"token,omitempty"`
+	TokenFile             string                 `yaml:"tokenFile,omitempty"`
+	Impersonate           string                 `yaml:"as,omitempty"`
+	ImpersonateUID        string                 `yaml:"as-uid,omitempty"`
+	ImpersonateGroups     []string               `yaml:"as-groups,omitempty"`
+	ImpersonateUserExtra  map[string][]string    `yaml:"as-user-extra,omitempty"`
 	Username              string                 `yaml:"username,omitempty"`
 	Password              string                 `yaml:"password,omitempty"`
-	AuthProvider          map[string]interface{} `yaml:"auth-provider,omitempty"`
-	Exec                  map[string]interface{} `yaml:"exec,omitempty"`
+	AuthProvider          *AuthProviderConfig    `yaml:"auth-provider,omitempty"`
+	Exec                  *ExecConfig            `yaml:"exec,omitempty"`
+	Extensions            []NamedExtension       `yaml:"extensions,omitempty"`
+	Extra                 map[string]interface{} `yaml:",inline"`
+}
+
+type AuthProviderConfig struct {
+	Name   string                 `yaml:"name"`
+	Config map[string]string      `yaml:"config,omitempty"`
+	Extra  map[string]interface{} `yaml:",inline"`
+}
+
+type ExecConfig struct {
+	APIVersion         string                 `yaml:"apiVersion,omitempty"`
+	Command            string                 `yaml:"command"`
+	Args               []string               `yaml:"args,omitempty"`
+	Env                []ExecEnvVar           `yaml:"env,omitempty"`
+	InstallHint        string                 `yaml:"installHint,omitempty"`
+	ProvideClusterInfo bool                   `yaml:"provideClusterInfo,omitempty"`
+	InteractiveMode    string                 `yaml:"interactiveMode,omitempty"`
+	Extra              map[string]interface{} `yaml:",inline"`
+}
+
+type ExecEnvVar struct {
+	Name  string                 `yaml:"name"`
+	Value string                 `yaml:"value"`
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 type ConfigInfo struct {
 	FilePath string
 	Contexts []string
+	Tags     map[string]map[string]string
 }
 
 var (
 	configDir       string
 	interactiveMode bool
 	listMode        bool
+	listSort        string
 	currentMode     bool
 	searchMode      bool
 	outputConfig    string
 	previousContext string
 	currentContext  string
+	assumeYes       bool
+
+	noStickyNamespace bool
+	nsContext         string
+	pickNamespaceStep bool
 )
 
 var productionKeywords = []string{"prd", "production"}
@@ -89,7 +152,9 @@ kjx() {
     local kjx_binary="%s"
     
     # Run kjx with output-config and pass all arguments
-    if command "$kjx_binary" --output-config "$temp_file" "$@"; then
+    command "$kjx_binary" --output-config "$temp_file" "$@"
+    local exit_code=$?
+    if [ $exit_code -eq 0 ]; then
         # Check if temp file exists and has content
         if [ -f "$temp_file" ] && [ -s "$temp_file" ]; then
             local new_kubeconfig=$(cat "$temp_file")
@@ -102,6 +167,7 @@ kjx() {
     
     # Clean up temp file
     rm -f "$temp_file" 2>/dev/null
+    return $exit_code
 }`
 
 func init() {
@@ -114,51 +180,287 @@ func main() {
 		Use:   "kjx",
 		Short: "KUBEJAX - Kubernetes Jump Across conteXts",
 		Long:  `KUBEJAX: A lightning-fast tool to jump across contexts and namespaces in multiple kubeconfig files`,
-		Run:   runContextSwitcher,
+		Args:  cobra.ArbitraryArgs,
+		RunE:  runContextSwitcher,
+
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
 	var nsCmd = &cobra.Command{
 		Use:   "ns",
 		Short: "Switch between namespaces",
-		Long:  `Switch between namespaces in the current context`,
-		Run:   runNamespaceSwitcher,
+		Long:  `Switch between namespaces in the current context, or in another context with --context`,
+		RunE:  runNamespaceSwitcher,
 	}
 
 	var shellInitCmd = &cobra.Command{
 		Use:   "shell-init",
 		Short: "Generate shell function for environment variable management",
 		Long:  `Generate shell function that properly exports KUBECONFIG environment variable`,
-		Run:   runShellInit,
+		RunE:  runShellInit,
 	}
 
 	var installCmd = &cobra.Command{
 		Use:   "install",
 		Short: "Install kjx shell function to your shell profile",
 		Long:  `Install kjx shell function to your ~/.bashrc or ~/.zshrc file`,
-		Run:   runInstall,
+		RunE:  runInstall,
+	}
+
+	var expiryCmd = &cobra.Command{
+		Use:   "expiry",
+		Short: "Show client certificate and token expiry",
+		Long:  `Show client certificate and bearer token expiry for all contexts in the config directory`,
+		RunE:  runExpiry,
+	}
+
+	var exportCmd = &cobra.Command{
+		Use:   "export <context>...",
+		Short: "Export contexts as a minimal, flattened kubeconfig",
+		Long:  `Export the selected contexts with only their clusters and users, inlining file-referenced certificates, as a standalone kubeconfig`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runExport,
+	}
+
+	var importCmd = &cobra.Command{
+		Use:   "import <file|->",
+		Short: "Import a kubeconfig, split into one file per context or cluster",
+		Long:  `Split a (merged) kubeconfig into one file per context or per cluster inside the config directory, skipping contexts that are already present and reusing clusters and users that exist with the same name and content`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runImport,
+	}
+
+	var renameCmd = &cobra.Command{
+		Use:   "rename <old> <new>",
+		Short: "Rename a context in the file that defines it",
+		Args:  cobra.ExactArgs(2),
+		RunE:  runRename,
+	}
+
+	var deleteCmd = &cobra.Command{
+		Use:   "delete <context>",
+		Short: "Delete a context from the file that defines it",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runDelete,
+	}
+
+	var copyCmd = &cobra.Command{
+		Use:   "copy <context> --to <file>",
+		Short: "Copy a context with its cluster and user into another kubeconfig file",
+		Long:  `Copy a context, together with the cluster and user it references, into another kubeconfig file. Relative file names are resolved in the config directory`,
+		Args:  cobra.ExactArgs(1),
+		RunE:  runCopy,
+	}
+
+	var previewCmd = &cobra.Command{
+		Use:   "preview <context>",
+		Short: "Show cluster, server, user, namespace and tier of a context (used by fzf/sk pickers)",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runPreview,
+	}
+
+	var execCmd = &cobra.Command{
+		Use:   "exec <context>[/<namespace>] -- <command> [args...]",
+		Short: "Run one command against a context without switching to it",
+		Long:  `Run a command with KUBECONFIG pointing at a temporary, isolated kubeconfig that holds only the given context (and namespace). Nothing is switched and no kubeconfig file is modified; the command's exit code is passed through`,
+		Example: `  kjx exec staging -- kubectl get pods
+  kjx exec prod-eu/payments --yes -- kubectl get deploy`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runExec,
+	}
+
+	var eachCmd = &cobra.Command{
+		Use:   "each [-s term] [--tag key=value] [--tier prod|non-prod] -- <command> [args...]",
+		Short: "Run one command against every matching context and summarize the exit codes",
+		Long:  `Run a command once per matching context, each with its own isolated kubeconfig (see kjx exec). Contexts run in parallel up to --concurrency; output is prefixed with the context name or grouped per context, and a summary of exit codes ends the run. kjx exits with 1 if any context failed or timed out`,
+		Example: `  kjx each --tier prod -- kubectl get deploy api -o jsonpath='{.spec.template.spec.containers[0].image}'
+  kjx each -s staging -j 8 --timeout 20s -- kubectl get nodes
+  kjx each --tag team=payments --output group -- kubectl get pods -n payments`,
+		RunE: runEach,
+	}
+
+	var shellCmd = &cobra.Command{
+		Use:   "shell <context>[/<namespace>]",
+		Short: "Start $SHELL with its own isolated kubeconfig for a context",
+		Long:  `Start $SHELL with KUBECONFIG pointing at a private, temporary kubeconfig that holds only the given context (and namespace). The parent shell and the kubeconfig files are not changed; the temporary file is removed when the shell exits. KJX_SHELL, KJX_TIER and KJX_PROMPT are set for use in the prompt`,
+		Example: `  kjx shell prod-eu/payments
+  PS1='${KJX_PROMPT}'"$PS1"   # in ~/.bashrc or ~/.zshrc`,
+		Args: cobra.ExactArgs(1),
+		RunE: runShell,
+	}
+
+	var findCmd = &cobra.Command{
+		Use:   "find <namespace>",
+		Short: "Find which contexts have a namespace and switch to one of them",
+		Long:  `Search the namespaces of all contexts, or of those selected with -s, --tag and --tier, for a name. Clusters are queried concurrently and answers are cached for 10 minutes. A single match is switched to (context and namespace); several open a picker`,
+		Example: `  kjx find payments-v2
+  kjx find payments --tier prod -o name`,
+		Args: cobra.ExactArgs(1),
+		RunE: runFind,
+	}
+
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log of context and namespace switches",
+	}
+
+	var auditLogCmd = &cobra.Command{
+		Use:   "log",
+		Short: "Show recorded context and namespace switches",
+		Long:  `Show the switches recorded in audit.log in the kjx home directory: time, OS user, host, tier, context, namespace, entry point and outcome`,
+		Example: `  kjx audit log --since 24h --tier prod
+  kjx audit log --context prod-eu -o json`,
+		Args: cobra.NoArgs,
+		RunE: runAuditLog,
+	}
+
+	var pinCmd = &cobra.Command{
+		Use:   "pin <context>...",
+		Short: "Pin contexts to the top of the picker, search results and kjx -l --sort=recent",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runPin,
+	}
+
+	var unpinCmd = &cobra.Command{
+		Use:   "unpin <context>...",
+		Short: "Unpin contexts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runPin,
+	}
+
+	var tagCmd = &cobra.Command{
+		Use:   "tag <context> [key=value | key-]...",
+		Short: "Show, set or remove context tags (team, region, cloud, owner, runbook, ...)",
+		Long:  `Show, set or remove context metadata. Tags are stored in the .kjx-metadata.yaml sidecar in the config directory; tags from a context's "kjx" extension are read as well`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runTag,
+	}
+
+	var addCmd = &cobra.Command{
+		Use:   "add [context]",
+		Short: "Add a new context with an interactive wizard",
+		Long:  `Add a new cluster, user and context to a kubeconfig file in the config directory. Every wizard question can also be answered with a flag`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  runAdd,
 	}
 
 	rootCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
 	rootCmd.Flags().BoolVarP(&interactiveMode, "interactive", "i", false, "Interactive mode with fuzzy search")
 	rootCmd.Flags().BoolVarP(&listMode, "list", "l", false, "List all available contexts")
 	rootCmd.Flags().BoolVarP(&currentMode, "current", "c", false, "Show current context information")
-	rootCmd.Flags().BoolVarP(&searchMode, "search", "s", false, "Search contexts by name")
-	rootCmd.Flags().StringVar(&outputConfig, "output-config", "", "Output selected config path to file")
+	rootCmd.Flags().BoolVarP(&searchMode, "search", "s", false, "Search contexts by name, cluster, server, user, namespace, file, alias or tag (e.g. server:eks, /regex/)")
+	// Persistent, because the shell function passes it before any subcommand.
+	rootCmd.PersistentFlags().StringVar(&outputConfig, "output-config", "", "Output selected config path to file")
+	rootCmd.Flags().IntVar(&expiryWarnDays, "warn-days", 30, "Warn about credentials expiring within this many days")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format for list, current and search: json|yaml|wide|name")
+	rootCmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "Only contexts with this tag (key=value or key), repeatable")
+	rootCmd.Flags().StringVar(&groupByTag, "group-by", "", "Group list and picker by file, tier or a tag (e.g. team, region)")
+	rootCmd.Flags().StringVar(&listSort, "sort", "file", "Order of -l: file|name|recent")
+	rootCmd.Flags().BoolVar(&searchFirst, "first", false, "With -s: switch to the best match when several contexts match")
+	rootCmd.Flags().BoolVar(&pickNamespaceStep, "ns", false, "With -i or -s: pick a namespace after the context")
+	rootCmd.Flags().BoolVar(&noStickyNamespace, "no-sticky-ns", false, "Keep the namespace in the kubeconfig instead of restoring the last one used")
 
 	nsCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
 	nsCmd.Flags().BoolVarP(&interactiveMode, "interactive", "i", false, "Interactive mode")
 	nsCmd.Flags().BoolVarP(&listMode, "list", "l", false, "List all available namespaces")
 	nsCmd.Flags().BoolVarP(&currentMode, "current", "c", false, "Show current namespace information")
 	nsCmd.Flags().BoolVarP(&searchMode, "search", "s", false, "Search namespaces by name")
+	nsCmd.Flags().BoolVar(&searchFirst, "first", false, "With -s: switch to the best match when several namespaces match")
 	nsCmd.Flags().StringVar(&outputConfig, "output-config", "", "Output selected config path to file")
+	nsCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format for list: json|yaml|wide|name")
+	nsCmd.Flags().StringVar(&nsContext, "context", "", "Switch the namespace of this context instead of the current one")
+
+	expiryCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	expiryCmd.Flags().IntVar(&expiryWarnDays, "warn-days", 30, "Warn about credentials expiring within this many days")
+
+	exportCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	exportCmd.Flags().StringVarP(&exportOutputFile, "output", "o", "", "Write the kubeconfig to this file instead of stdout")
+
+	importCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	importCmd.Flags().StringVar(&importSplitBy, "split", "context", "Write one file per: context|cluster")
+	importCmd.Flags().BoolVar(&importForce, "force", false, "Replace conflicting contexts, clusters and users in existing files")
+	importCmd.Flags().BoolVar(&importRename, "rename", false, "Rename conflicting contexts and files without asking")
+
+	for _, cmd := range []*cobra.Command{renameCmd, deleteCmd, copyCmd, execCmd} {
+		cmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+		cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation on production contexts")
+	}
+	deleteCmd.Flags().BoolVar(&lifecycleGC, "gc", false, "Also remove clusters and users no longer referenced by any context")
+	copyCmd.Flags().StringVar(&copyTargetFile, "to", "", "Target kubeconfig file (created if missing)")
+	copyCmd.Flags().StringVar(&copyContextName, "name", "", "Name for the copied context (default: same name)")
+	copyCmd.MarkFlagRequired("to")
+
+	eachCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	eachCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation on production contexts")
+	eachCmd.Flags().StringVarP(&eachSearch, "search", "s", "", "Only contexts matching this search term (as in kjx -s)")
+	eachCmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "Only contexts with this tag (key=value or key), repeatable")
+	eachCmd.Flags().StringVar(&eachTier, "tier", "", "Only contexts of this tier: prod|non-prod")
+	eachCmd.Flags().IntVarP(&eachConcurrency, "concurrency", "j", 4, "Number of contexts to run at the same time")
+	eachCmd.Flags().DurationVar(&eachTimeout, "timeout", 0, "Stop the command in a context after this long, e.g. 30s (0 = no limit)")
+	eachCmd.Flags().StringVar(&eachOutput, "output", "prefix", "Output mode: prefix (lines tagged with the context) or group (one block per context)")
+	eachCmd.Flags().SetInterspersed(false)
+
+	addCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	tagCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	previewCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	shellCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	findCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	findCmd.Flags().StringVarP(&findSearch, "search", "s", "", "Only contexts matching this search term (as in kjx -s)")
+	findCmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "Only contexts with this tag (key=value or key), repeatable")
+	findCmd.Flags().StringVar(&findTier, "tier", "", "Only contexts of this tier: prod|non-prod")
+	findCmd.Flags().IntVarP(&findConcurrency, "concurrency", "j", 8, "Number of clusters to query at the same time")
+	findCmd.Flags().DurationVar(&findTimeout, "timeout", 10*time.Second, "Give up on a cluster after this long")
+	findCmd.Flags().BoolVar(&findRefresh, "refresh", false, "Ignore the namespace cache and ask every cluster")
+	findCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Print matches instead of switching: json|yaml|wide|name")
+	auditLogCmd.Flags().StringVar(&auditSince, "since", "", "Only records since a duration ago (24h, 7d) or a date (2026-01-31)")
+	auditLogCmd.Flags().StringVar(&auditTier, "tier", "", "Only records of this tier: prod|non-prod")
+	auditLogCmd.Flags().StringVar(&auditContext, "context", "", "Only records for this context")
+	auditLogCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "Output format: json (one record per line)")
+	auditCmd.AddCommand(auditLogCmd)
+	pinCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	unpinCmd.Flags().StringVarP(&configDir, "config-dir", "d", configDir, "Directory containing kubeconfig files")
+	addCmd.Flags().StringVar(&addOpts.Name, "name", "", "Context name")
+	addCmd.Flags().StringVar(&addOpts.Server, "server", "", "API server URL")
+	addCmd.Flags().StringVar(&addOpts.CA, "ca", "", "CA certificate file, PEM text or base64 data")
+	addCmd.Flags().BoolVar(&addOpts.Insecure, "insecure-skip-tls-verify", false, "Do not verify the API server certificate")
+	addCmd.Flags().StringVar(&addOpts.AuthMethod, "auth", "", "Authentication method: token|cert|exec")
+	addCmd.Flags().StringVar(&addOpts.Token, "token", "", "Bearer token (--auth token)")
+	addCmd.Flags().StringVar(&addOpts.ClientCert, "client-certificate", "", "Client certificate file (--auth cert)")
+	addCmd.Flags().StringVar(&addOpts.ClientKey, "client-key", "", "Client key file (--auth cert)")
+	addCmd.Flags().StringVar(&addOpts.ExecCommand, "exec-command", "", "Exec credential plugin command (--auth exec)")
+	addCmd.Flags().StringSliceVar(&addOpts.ExecArgs, "exec-arg", nil, "Exec credential plugin argument, repeatable (--auth exec)")
+	addCmd.Flags().StringVar(&addOpts.ExecAPIVersion, "exec-api-version", "", "Exec credential plugin API version (--auth exec)")
+	addCmd.Flags().StringVarP(&addOpts.Namespace, "namespace", "n", "", "Default namespace")
+	addCmd.Flags().StringVarP(&addOpts.File, "file", "f", "", "Target kubeconfig file (default: <name>.yaml in the config directory)")
+	addCmd.Flags().BoolVar(&addOpts.NonInteractive, "non-interactive", false, "Never prompt; fail if required flags are missing")
+	addCmd.Flags().BoolVar(&addOpts.SkipValidate, "skip-validate", false, "Save without checking connectivity to the API server")
 
 	rootCmd.AddCommand(nsCmd)
 	rootCmd.AddCommand(shellInitCmd)
 	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(expiryCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(tagCmd)
+	rootCmd.AddCommand(previewCmd)
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(eachCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(findCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		if _, ok := err.(*ExitStatus); !ok {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(exitCodeFor(err))
 	}
 }
 
@@ -236,180 +538,153 @@ func isProductionEnvironmentCombined(contextName, configFilePath string) bool {
 	return isProductionEnvironment(contextName) || isProductionConfigFile(configFilePath)
 }
 
-func searchContexts(configInfos []ConfigInfo, searchTerm string) []string {
-	var matches []string
-	searchLower := strings.ToLower(searchTerm)
-	
-	for _, configInfo := range configInfos {
-		for _, context := range configInfo.Contexts {
-			if strings.Contains(strings.ToLower(context), searchLower) {
-				matches = append(matches, context)
-			}
-		}
+func environmentTier(contextName, configFilePath string) string {
+	if isProductionEnvironmentCombined(contextName, configFilePath) {
+		return "prod"
 	}
-	
-	sort.Strings(matches)
-	return matches
+	return "non-prod"
 }
 
-func searchNamespaces(namespaces []string, searchTerm string) []string {
-	var matches []string
-	searchLower := strings.ToLower(searchTerm)
-	
-	for _, namespace := range namespaces {
-		if strings.Contains(strings.ToLower(namespace), searchLower) {
-			matches = append(matches, namespace)
-		}
+func currentKubeconfigPath() string {
+	kubeconfig := os.Getenv("KUBECONFIG")
+	if kubeconfig == "" {
+		homeDir, _ := os.UserHomeDir()
+		kubeconfig = filepath.Join(homeDir, ".kube", "config")
 	}
-	
-	sort.Strings(matches)
-	return matches
+	return kubeconfig
 }
 
-func interactiveContextSearch(configInfos []ConfigInfo) error {
-	var allContexts []string
-	var contextFileMap = make(map[string]string)
-	
-	for _, configInfo := range configInfos {
-		for _, context := range configInfo.Contexts {
-			allContexts = append(allContexts, context)
-			contextFileMap[context] = configInfo.FilePath
-		}
-	}
-	
-	if len(allContexts) == 0 {
-		return fmt.Errorf("no contexts available")
-	}
-	
-	searcher := promptui.Select{
-		Label: "Search and select context (type to filter)",
-		Items: allContexts,
-		Size:  15,
-		Searcher: func(input string, index int) bool {
-			context := allContexts[index]
-			name := strings.Replace(strings.ToLower(context), " ", "", -1)
-			input = strings.Replace(strings.ToLower(input), " ", "", -1)
-			return strings.Contains(name, input)
-		},
-	}
-	
-	_, result, err := searcher.Run()
+// stdinIsTerminal reports whether kjx can ask questions. Other character
+// devices such as /dev/null (CI, cron) do not count.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// searchContexts returns the names of the contexts matching searchTerm, best
+// match first.
+func searchContexts(configInfos []ConfigInfo, searchTerm string) ([]string, error) {
+	matches, err := searchContextMatches(configInfos, searchTerm)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	
-	filePath := contextFileMap[result]
-	
-	if isProductionEnvironmentCombined(result, filePath) {
-		showProductionWarning(result, filePath)
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Name)
 	}
-	
-	return setKubeConfig(filePath, result)
+	return names, nil
 }
 
-func interactiveNamespaceSearch() error {
-	namespaces, err := getLiveNamespaces()
+func searchNamespaces(namespaces []string, searchTerm string) []string {
+	return fuzzyFilter(namespaces, searchTerm)
+}
+
+func interactiveNamespaceSearch(target NamespaceTarget) error {
+	namespaces, err := getLiveNamespaces(target)
 	if err != nil {
-		return fmt.Errorf("could not get namespaces: %v", err)
+		return err
 	}
 	
 	if len(namespaces) == 0 {
-		return fmt.Errorf("no namespaces found")
+		return notFoundError("no namespaces found")
 	}
 	
-	searcher := promptui.Select{
+	picked, err := pick(PickRequest{
 		Label: "Search and select namespace (type to filter)",
-		Items: namespaces,
+		Items: plainPickerItems(namespaces),
 		Size:  15,
-		Searcher: func(input string, index int) bool {
-			namespace := namespaces[index]
-			name := strings.Replace(strings.ToLower(namespace), " ", "", -1)
-			input = strings.Replace(strings.ToLower(input), " ", "", -1)
-			return strings.Contains(name, input)
-		},
-	}
-	
-	_, result, err := searcher.Run()
+	})
 	if err != nil {
 		return err
 	}
 	
-	currentConfig := os.Getenv("KUBECONFIG")
-	if currentConfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		currentConfig = filepath.Join(homeDir, ".kube", "config")
-	}
-	
-	kubeconfig, err := loadKubeConfig(currentConfig)
-	if err != nil {
-		return err
-	}
-	
-	return switchToNamespace(result, kubeconfig, currentConfig)
+	switchEntryPoint = entryPicker
+	return switchToNamespace(namespaces[picked.Index], target)
 }
 
 func showProductionWarning(contextName, configFilePath string) {
-	fmt.Println("⚠️  WARNING: PRODUCTION ENVIRONMENT DETECTED!")
-	fmt.Printf("🔴 You are selecting context: '%s'\n", contextName)
+	fmt.Fprintln(os.Stderr, "⚠️  WARNING: PRODUCTION ENVIRONMENT DETECTED!")
+	fmt.Fprintf(os.Stderr, "🔴 You are selecting context: '%s'\n", contextName)
 	
 	if isProductionEnvironment(contextName) {
-		fmt.Printf("🔴 Context name contains production keywords\n")
+		fmt.Fprintf(os.Stderr, "🔴 Context name contains production keywords\n")
 	}
 	if isProductionConfigFile(configFilePath) {
-		fmt.Printf("🔴 Config file '%s' contains production keywords\n", filepath.Base(configFilePath))
+		fmt.Fprintf(os.Stderr, "🔴 Config file '%s' contains production keywords\n", filepath.Base(configFilePath))
 	}
 	
-	fmt.Println("🔴 This appears to be a PRODUCTION cluster.")
-	fmt.Println("🔴 Please be extra careful with any changes!")
-	fmt.Println()
+	fmt.Fprintln(os.Stderr, "🔴 This appears to be a PRODUCTION cluster.")
+	fmt.Fprintln(os.Stderr, "🔴 Please be extra careful with any changes!")
+	fmt.Fprintln(os.Stderr)
 }
 
+// confirmProductionAction asks before acting on a production context. It
+// refuses outright when there is no terminal to ask on and --yes was not given.
+func confirmProductionAction(action, contextName, configFilePath string) error {
+	if !isProductionEnvironmentCombined(contextName, configFilePath) || assumeYes {
+		return nil
+	}
+
+	showProductionWarning(contextName, configFilePath)
+
+	if !stdinIsTerminal() {
+		return refusedError("refusing to %s PRODUCTION context '%s' without confirmation (use --yes)", action, contextName)
+	}
+
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Really %s PRODUCTION context '%s'", action, contextName),
+		IsConfirm: true,
+	}
+	if _, err := prompt.Run(); err != nil {
+		return abortedError("%s of '%s' aborted by user", action, contextName)
+	}
+
+	return nil
+}
+
+// getCurrentContextInfo describes the current context and the file that
+// defines it, which may be any file of a $KUBECONFIG path list.
 func getCurrentContextInfo() (contextName, configFile, clusterName, namespace string) {
-	kubeconfig := os.Getenv("KUBECONFIG")
-	if kubeconfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		kubeconfig = filepath.Join(homeDir, ".kube", "config")
+	target, err := resolveNamespaceTarget("")
+	if err != nil {
+		return "", "", "", ""
 	}
 
-	config, err := loadKubeConfig(kubeconfig)
+	config, err := loadKubeConfig(target.File)
 	if err != nil {
 		return "", "", "", ""
 	}
 
-	contextName = config.CurrentContext
-	configFile = filepath.Base(kubeconfig)
+	contextName = target.Context
+	configFile = target.File
 
-	for _, ctx := range config.Contexts {
-		if ctx.Name == contextName {
-			clusterName = ctx.Context.Cluster
-			namespace = ctx.Context.Namespace
-			if namespace == "" {
-				namespace = "default"
-			}
-			break
-		}
+	if detail, ok := findContextDetail(config, contextName); ok {
+		clusterName = detail.Cluster
+		namespace = detail.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
 	}
 
 	return contextName, configFile, clusterName, namespace
 }
 
-func runShellInit(cmd *cobra.Command, args []string) {
+func runShellInit(cmd *cobra.Command, args []string) error {
 	execPath, err := os.Executable()
 	if err != nil {
 		execPath = "kjx"
 	}
 	
 	fmt.Printf(shellFunction, execPath)
+	return nil
 }
 
-func runInstall(cmd *cobra.Command, args []string) {
+func runInstall(cmd *cobra.Command, args []string) error {
 	shell := os.Getenv("SHELL")
 	var profileFile string
 	
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("Error: Could not get home directory: %v\n", err)
-		return
+		return fmt.Errorf("could not get home directory: %v", err)
 	}
 	
 	if strings.Contains(shell, "zsh") {
@@ -417,9 +692,7 @@ func runInstall(cmd *cobra.Command, args []string) {
 	} else if strings.Contains(shell, "bash") {
 		profileFile = filepath.Join(homeDir, ".bashrc")
 	} else {
-		fmt.Println("Unsupported shell. Please manually add the shell function to your profile.")
-		fmt.Println("Run 'kjx shell-init' to get the function code.")
-		return
+		return fmt.Errorf("unsupported shell '%s'; run 'kjx shell-init' and add the function to your profile manually", shell)
 	}
 	
 	execPath, err := os.Executable()
@@ -433,179 +706,223 @@ func runInstall(cmd *cobra.Command, args []string) {
 		content, err := ioutil.ReadFile(profileFile)
 		if err == nil && strings.Contains(string(content), "KUBEJAX shell function") {
 			fmt.Printf("KUBEJAX shell function already exists in %s\n", profileFile)
-			return
+			return nil
 		}
 	}
 	
 	f, err := os.OpenFile(profileFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		fmt.Printf("Error: Could not open %s: %v\n", profileFile, err)
-		return
+		return fmt.Errorf("could not open %s: %v", profileFile, err)
 	}
 	defer f.Close()
 	
 	if _, err := f.WriteString(functionCode); err != nil {
-		fmt.Printf("Error: Could not write to %s: %v\n", profileFile, err)
-		return
+		return fmt.Errorf("could not write to %s: %v", profileFile, err)
 	}
 	
 	fmt.Printf("✅ KUBEJAX shell function installed to %s\n", profileFile)
 	fmt.Printf("Please run: source %s\n", profileFile)
 	fmt.Println("Or restart your shell to use the function.")
+	return nil
 }
 
-func runContextSwitcher(cmd *cobra.Command, args []string) {
+func runContextSwitcher(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(); err != nil {
+		return err
+	}
+
 	if currentMode {
-		showCurrentContextInfo()
-		return
+		if outputFormat != "" {
+			return printCurrentContext()
+		}
+		return showCurrentContextInfo()
 	}
 
 	configInfos, err := loadAllKubeConfigs()
 	if err != nil {
-		fmt.Printf("Error loading kubeconfigs: %v\n", err)
-		return
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	if len(configInfos) == 0 {
+		return notFoundError("no kubeconfig files found in %s", configDir)
 	}
 
+	filters, err := parseTagFilters(tagFilters)
+	if err != nil {
+		return err
+	}
+	configInfos = filterConfigInfosByTags(configInfos, filters)
 	if len(configInfos) == 0 {
-		fmt.Printf("No kubeconfig files found in %s\n", configDir)
-		return
+		return notFoundError("no contexts match tags %s", strings.Join(tagFilters, ", "))
 	}
 
 	currentContext = getCurrentContext()
 
 	if searchMode {
-		if len(args) > 0 {
-			searchTerm := args[0]
-			matches := searchContexts(configInfos, searchTerm)
-			if len(matches) == 0 {
-				fmt.Printf("No contexts found matching '%s'\n", searchTerm)
-				return
-			}
-			
-			fmt.Printf("Contexts matching '%s':\n", searchTerm)
-			for i, match := range matches {
-				marker := "  "
-				if match == currentContext {
-					marker = "🔹"
-				}
-				
-				var matchFilePath string
-				for _, configInfo := range configInfos {
-					for _, context := range configInfo.Contexts {
-						if context == match {
-							matchFilePath = configInfo.FilePath
-							break
-						}
-					}
-					if matchFilePath != "" {
-						break
-					}
-				}
-				
-				prodIndicator := ""
-				if isProductionEnvironmentCombined(match, matchFilePath) {
-					prodIndicator = " 🔴"
+		if len(args) == 0 {
+			return interactiveContextSelect(configInfos, ContextPickerOptions{StartInSearch: true})
+		}
+
+		searchTerm := args[0]
+		contextMatches, err := searchContextMatches(configInfos, searchTerm)
+		if err != nil {
+			return err
+		}
+		contextTerm, namespaceTerm := searchTerm, ""
+		if len(contextMatches) == 0 {
+			if term, nsTerm, ok := splitTargetTerm(searchTerm); ok {
+				if contextMatches, err = searchContextMatches(configInfos, term); err != nil {
+					return err
 				}
-				fmt.Printf("%d) %s %s%s\n", i+1, marker, match, prodIndicator)
+				contextTerm, namespaceTerm = term, nsTerm
+			}
+		}
+		var matches []string
+		for _, match := range contextMatches {
+			// A context named exactly like the term beats matches found
+			// through other fields.
+			if match.Name == contextTerm && len(args) == 1 && outputFormat == "" {
+				contextMatches, matches = []ContextMatch{match}, []string{match.Name}
+				break
+			}
+			matches = append(matches, match.Name)
+		}
+		if outputFormat != "" {
+			return printContextRecords(buildContextRecords(configInfos, matches))
+		}
+		if len(matches) == 0 {
+			return notFoundError("no contexts found matching '%s'", searchTerm)
+		}
+
+		choice, err := searchChoice(args, len(matches), "contexts")
+		if err != nil {
+			return err
+		}
+		if choice < 0 && stdinIsTerminal() {
+			return interactiveContextSelect(configInfos, ContextPickerOptions{
+				SearchTerm:    searchTerm,
+				Matches:       matches,
+				NamespaceTerm: namespaceTerm,
+			})
+		}
+		
+		fmt.Printf("Contexts matching '%s':\n", searchTerm)
+		for i, contextMatch := range contextMatches {
+			match := contextMatch.Name
+			marker := "  "
+			if match == currentContext {
+				marker = "🔹"
 			}
 			
-			if len(matches) == 1 {
-				fmt.Printf("\nOnly one match found. Switching to '%s'...\n", matches[0])
-				
-				var matchFilePath string
-				for _, configInfo := range configInfos {
-					for _, context := range configInfo.Contexts {
-						if context == matches[0] {
-							matchFilePath = configInfo.FilePath
-							break
-						}
-					}
-					if matchFilePath != "" {
-						break
-					}
-				}
-				
-				if isProductionEnvironmentCombined(matches[0], matchFilePath) {
-					showProductionWarning(matches[0], matchFilePath)
-				}
-				if err := switchToContext(matches[0], configInfos); err != nil {
-					fmt.Printf("Error switching context: %v\n", err)
-				}
+			prodIndicator := ""
+			if isProductionEnvironmentCombined(match, findContextFile(configInfos, match)) {
+				prodIndicator = " 🔴"
+			}
+			tagSuffix := ""
+			if tags := formatTags(contextTags(configInfos, match)); tags != "" {
+				tagSuffix = "  [" + tags + "]"
 			}
-			return
-		} else {
-			if err := interactiveContextSearch(configInfos); err != nil {
-				fmt.Printf("Error: %v\n", err)
+			matchedSuffix := ""
+			if len(contextMatch.Fields) > 0 {
+				matchedSuffix = "  (" + formatSearchFields(contextMatch.Fields) + ")"
+			}
+			fmt.Printf("%d) %s %s%s%s%s\n", i+1, marker, match, prodIndicator, tagSuffix, matchedSuffix)
+		}
+		
+		if choice < 0 {
+			return searchAmbiguousError("kjx -s", len(matches), "contexts", searchTerm)
+		}
+		
+		match := matches[choice]
+		matchFilePath := findContextFile(configInfos, match)
+		namespace := ""
+		target := match
+		if namespaceTerm != "" {
+			nsTarget := NamespaceTarget{Context: match, File: matchFilePath, Outside: true}
+			if namespace, err = searchTargetNamespace(nsTarget, namespaceTerm); err != nil {
+				return err
 			}
-			return
+			target += "/" + namespace
+		}
+		switch {
+		case len(matches) == 1:
+			fmt.Printf("\nOnly one match found. Switching to '%s'...\n", target)
+		case len(args) > 1:
+			fmt.Printf("\nPicked match %d. Switching to '%s'...\n", choice+1, target)
+		default:
+			fmt.Printf("\nPicked the best match. Switching to '%s'...\n", target)
+		}
+		
+		if isProductionEnvironmentCombined(match, matchFilePath) {
+			showProductionWarning(match, matchFilePath)
 		}
+		switchEntryPoint = entrySearch
+		return switchToContext(match, namespace, configInfos)
 	}
 
 	if listMode {
+		if err := validateListSort(); err != nil {
+			return err
+		}
+		if outputFormat != "" {
+			return printContextRecords(buildContextRecords(configInfos, sortedContextNames(configInfos)))
+		}
 		listAllContexts(configInfos)
-		return
+		return nil
 	}
 
 	if len(args) == 0 || interactiveMode {
-		if err := interactiveContextSelect(configInfos); err != nil {
-			fmt.Printf("Error: %v\n", err)
-		}
-		return
+		return interactiveContextSelect(configInfos, ContextPickerOptions{})
 	}
 
-	contextName := args[0]
+	contextName, namespace := args[0], ""
 	if contextName == "-" {
 		if previousContext == "" {
-			fmt.Println("No previous context available")
-			return
+			return notFoundError("no previous context available")
 		}
 		contextName = previousContext
+	} else {
+		if contextName, namespace, err = parseContextTarget(configInfos, args[0]); err != nil {
+			return err
+		}
 	}
 
-	if isProductionEnvironment(contextName) {
-		var filePath string
-		for _, configInfo := range configInfos {
-			for _, context := range configInfo.Contexts {
-				if context == contextName {
-					filePath = configInfo.FilePath
-					break
-				}
-			}
-			if filePath != "" {
-				break
-			}
+	filePath := findContextFile(configInfos, contextName)
+	if namespace != "" {
+		target := NamespaceTarget{Context: contextName, File: filePath, Outside: true}
+		if err := validateNamespace(target, namespace); err != nil {
+			return err
 		}
-		showProductionWarning(contextName, filePath)
 	}
 
-	if err := switchToContext(contextName, configInfos); err != nil {
-		fmt.Printf("Error switching context: %v\n", err)
+	if isProductionEnvironment(contextName) {
+		showProductionWarning(contextName, filePath)
 	}
+
+	return switchToContext(contextName, namespace, configInfos)
 }
 
-func showCurrentContextInfo() {
+func showCurrentContextInfo() error {
 	contextName, configFile, clusterName, namespace := getCurrentContextInfo()
 	
 	if contextName == "" {
-		fmt.Println("❌ No current context found or invalid kubeconfig")
-		return
+		return notFoundError("no current context found or invalid kubeconfig")
 	}
 
 	fmt.Println("📍 Current Kubernetes Context Information:")
 	fmt.Println("=" + strings.Repeat("=", 45))
 	fmt.Printf("🔹 Context: %s\n", contextName)
-	fmt.Printf("📁 Config File: %s\n", configFile)
+	fmt.Printf("📁 Config File: %s\n", filepath.Base(configFile))
 	fmt.Printf("🏗️  Cluster: %s\n", clusterName)
 	fmt.Printf("📦 Namespace: %s\n", namespace)
 	
-	currentKubeconfig := os.Getenv("KUBECONFIG")
-	if currentKubeconfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		currentKubeconfig = filepath.Join(homeDir, ".kube", "config")
-	}
+	currentKubeconfig := currentKubeconfigPath()
+	
+	showCurrentContextMetadata(configFile, contextName)
+	showCredentialExpiry(configFile, contextName)
 	
 	isProdContext := isProductionEnvironment(contextName) || isProductionEnvironment(clusterName)
-	isProdFile := isProductionConfigFile(currentKubeconfig)
+	isProdFile := isProductionConfigFile(configFile)
 	
 	if isProdContext || isProdFile {
 		fmt.Println()
@@ -615,99 +932,126 @@ func showCurrentContextInfo() {
 			fmt.Printf("🔴 Context/Cluster '%s' appears to be a production environment\n", contextName)
 		}
 		if isProdFile {
-			fmt.Printf("🔴 Config file '%s' appears to be a production environment\n", configFile)
+			fmt.Printf("🔴 Config file '%s' appears to be a production environment\n", filepath.Base(configFile))
 		}
 		
 		fmt.Println("🔴 Please be extra careful with any operations!")
 	}
 	
 	fmt.Printf("\n💾 KUBECONFIG: %s\n", currentKubeconfig)
+	return nil
 }
 
-func runNamespaceSwitcher(cmd *cobra.Command, args []string) {
-	if currentMode {
-		showCurrentNamespaceInfo()
-		return
+func runNamespaceSwitcher(cmd *cobra.Command, args []string) error {
+	if err := validateOutputFormat(); err != nil {
+		return err
 	}
 
-	currentConfig := os.Getenv("KUBECONFIG")
-	if currentConfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		currentConfig = filepath.Join(homeDir, ".kube", "config")
+	if currentMode {
+		return showCurrentNamespaceInfo()
 	}
 
-	kubeconfig, err := loadKubeConfig(currentConfig)
+	target, err := resolveNamespaceTarget(nsContext)
 	if err != nil {
-		fmt.Printf("Error loading current kubeconfig: %v\n", err)
-		return
+		return err
 	}
 
 	if searchMode {
-		if len(args) > 0 {
-			searchTerm := args[0]
-			namespaces, err := getLiveNamespaces()
+		if len(args) == 0 {
+			return interactiveNamespaceSearch(target)
+		}
+
+		searchTerm := args[0]
+		namespaces, err := getLiveNamespaces(target)
+		if err != nil {
+			return err
+		}
+		
+		matches := searchNamespaces(namespaces, searchTerm)
+		if len(matches) == 0 {
+			return notFoundError("no namespaces found matching '%s'", searchTerm)
+		}
+
+		choice, err := searchChoice(args, len(matches), "namespaces")
+		if err != nil {
+			return err
+		}
+		if choice < 0 && stdinIsTerminal() {
+			picked, err := pick(PickRequest{
+				Label: fmt.Sprintf("Select from namespaces matching '%s' (type to filter)", searchTerm),
+				Items: plainPickerItems(matches),
+				Size:  15,
+			})
 			if err != nil {
-				fmt.Printf("Error getting namespaces: %v\n", err)
-				return
+				return err
 			}
-			
-			matches := searchNamespaces(namespaces, searchTerm)
-			if len(matches) == 0 {
-				fmt.Printf("No namespaces found matching '%s'\n", searchTerm)
-				return
-			}
-			
-			fmt.Printf("Namespaces matching '%s':\n", searchTerm)
-			for i, match := range matches {
-				fmt.Printf("%d) %s\n", i+1, match)
-			}
-			
-			if len(matches) == 1 {
-				fmt.Printf("\nOnly one match found. Switching to namespace '%s'...\n", matches[0])
-				if err := switchToNamespace(matches[0], kubeconfig, currentConfig); err != nil {
-					fmt.Printf("Error switching namespace: %v\n", err)
-				}
-			}
-			return
-		} else {
-			if err := interactiveNamespaceSearch(); err != nil {
-				fmt.Printf("Error: %v\n", err)
-			}
-			return
+			switchEntryPoint = entryPicker
+			return switchToNamespace(matches[picked.Index], target)
+		}
+		
+		fmt.Printf("Namespaces matching '%s':\n", searchTerm)
+		for i, match := range matches {
+			fmt.Printf("%d) %s\n", i+1, match)
 		}
+		
+		if choice < 0 {
+			return searchAmbiguousError("kjx ns -s", len(matches), "namespaces", searchTerm)
+		}
+		
+		switch {
+		case len(matches) == 1:
+			fmt.Printf("\nOnly one match found. Switching to namespace '%s'...\n", matches[choice])
+		case len(args) > 1:
+			fmt.Printf("\nPicked match %d. Switching to namespace '%s'...\n", choice+1, matches[choice])
+		default:
+			fmt.Printf("\nPicked the best match. Switching to namespace '%s'...\n", matches[choice])
+		}
+		switchEntryPoint = entrySearch
+		return switchToNamespace(matches[choice], target)
 	}
 
 	if listMode {
-		namespaces, err := getLiveNamespaces()
+		namespaces, err := getLiveNamespaces(target)
 		if err != nil {
-			fmt.Printf("Error getting namespaces: %v\n", err)
-			return
+			return err
 		}
 		
-		fmt.Println("Available namespaces in current cluster:")
+		if outputFormat != "" {
+			currentNamespace := contextNamespace(target.File, target.Context)
+			if currentNamespace == "" {
+				currentNamespace = "default"
+			}
+			var records []NamespaceRecord
+			for _, ns := range namespaces {
+				records = append(records, NamespaceRecord{
+					Name:    ns,
+					Context: target.Context,
+					Current: ns == currentNamespace,
+				})
+			}
+			return printNamespaceRecords(records)
+		}
+		
+		fmt.Printf("Available namespaces in context '%s':\n", target.Context)
 		for _, ns := range namespaces {
 			fmt.Printf("  %s\n", ns)
 		}
-		return
+		return nil
 	}
 
 	if len(args) == 0 || interactiveMode {
-		if err := interactiveNamespaceSelect(kubeconfig); err != nil {
-			fmt.Printf("Error: %v\n", err)
-		}
-		return
+		return interactiveNamespaceSelect(target)
 	}
 
 	namespace := args[0]
 	if namespace == "-" {
-		fmt.Println("Previous namespace switching not implemented yet")
-		return
+		return fmt.Errorf("previous namespace switching not implemented yet")
 	}
 
-	namespaces, err := getLiveNamespaces()
+	namespaces, err := getLiveNamespaces(target)
 	if err != nil {
-		fmt.Printf("Warning: Could not verify namespace exists: %v\n", err)
-		fmt.Printf("Switching to namespace '%s' anyway...\n", namespace)
+		fmt.Fprintf(os.Stderr, "Warning: Could not verify namespace exists: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Switching to namespace '%s' anyway...\n", namespace)
 	} else {
 		found := false
 		for _, ns := range namespaces {
@@ -717,23 +1061,19 @@ func runNamespaceSwitcher(cmd *cobra.Command, args []string) {
 			}
 		}
 		if !found {
-			fmt.Printf("Warning: Namespace '%s' not found in cluster\n", namespace)
-			fmt.Printf("Available namespaces: %s\n", strings.Join(namespaces, ", "))
-			return
+			fmt.Fprintf(os.Stderr, "Available namespaces: %s\n", strings.Join(namespaces, ", "))
+			return notFoundError("namespace '%s' not found in cluster", namespace)
 		}
 	}
 
-	if err := switchToNamespace(namespace, kubeconfig, currentConfig); err != nil {
-		fmt.Printf("Error switching namespace: %v\n", err)
-	}
+	return switchToNamespace(namespace, target)
 }
 
-func showCurrentNamespaceInfo() {
+func showCurrentNamespaceInfo() error {
 	contextName, configFile, clusterName, namespace := getCurrentContextInfo()
 	
 	if contextName == "" {
-		fmt.Println("❌ No current context found or invalid kubeconfig")
-		return
+		return notFoundError("no current context found or invalid kubeconfig")
 	}
 
 	fmt.Println("📦 Current Kubernetes Namespace Information:")
@@ -741,16 +1081,10 @@ func showCurrentNamespaceInfo() {
 	fmt.Printf("📦 Current Namespace: %s\n", namespace)
 	fmt.Printf("🔹 Context: %s\n", contextName)
 	fmt.Printf("🏗️  Cluster: %s\n", clusterName)
-	fmt.Printf("📁 Config File: %s\n", configFile)
+	fmt.Printf("📁 Config File: %s\n", filepath.Base(configFile))
 
-	currentKubeconfig := os.Getenv("KUBECONFIG")
-	if currentKubeconfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		currentKubeconfig = filepath.Join(homeDir, ".kube", "config")
-	}
-	
 	isProdContext := isProductionEnvironment(contextName) || isProductionEnvironment(clusterName)
-	isProdFile := isProductionConfigFile(currentKubeconfig)
+	isProdFile := isProductionConfigFile(configFile)
 	
 	if isProdContext || isProdFile {
 		fmt.Println()
@@ -767,6 +1101,7 @@ func showCurrentNamespaceInfo() {
 		
 		fmt.Println("🔴 Please be extra careful with any operations!")
 	}
+	return nil
 }
 
 func loadAllKubeConfigs() ([]ConfigInfo, error) {
@@ -781,6 +1116,11 @@ func loadAllKubeConfigs() ([]ConfigInfo, error) {
 		return nil, err
 	}
 
+	metadata, err := loadMetadataFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not load %s: %v\n", metadataFileName, err)
+	}
+
 	for _, file := range files {
 		if file.IsDir() {
 			continue
@@ -797,19 +1137,24 @@ func loadAllKubeConfigs() ([]ConfigInfo, error) {
 
 		kubeconfig, err := loadKubeConfig(filePath)
 		if err != nil {
-			fmt.Printf("Warning: Could not load %s: %v\n", file.Name(), err)
+			fmt.Fprintf(os.Stderr, "Warning: Could not load %s: %v\n", file.Name(), err)
 			continue
 		}
 
 		var contexts []string
+		tags := make(map[string]map[string]string)
 		for _, ctx := range kubeconfig.Contexts {
 			contexts = append(contexts, ctx.Name)
+			if contextTags := mergedContextTags(ctx.Context, ctx.Name, metadata); contextTags != nil {
+				tags[ctx.Name] = contextTags
+			}
 		}
 
 		if len(contexts) > 0 {
 			configInfos = append(configInfos, ConfigInfo{
 				FilePath: filePath,
 				Contexts: contexts,
+				Tags:     tags,
 			})
 		}
 	}
@@ -817,12 +1162,19 @@ func loadAllKubeConfigs() ([]ConfigInfo, error) {
 	return configInfos, nil
 }
 
-func getLiveNamespaces() ([]string, error) {
-	cmd := exec.Command("kubectl", "get", "namespaces", "-o", "name", "--no-headers")
+func getLiveNamespaces(target NamespaceTarget) ([]string, error) {
+	return getLiveNamespacesContext(context.Background(), target)
+}
+
+// getLiveNamespacesContext is getLiveNamespaces with a deadline or cancel.
+func getLiveNamespacesContext(ctx context.Context, target NamespaceTarget) ([]string, error) {
+	args := append(target.kubectlArgs(), "get", "namespaces", "-o", "name", "--no-headers")
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.WaitDelay = time.Second
 	
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute kubectl: %v", err)
+		return nil, unreachableError("failed to get namespaces via kubectl: %v", err)
 	}
 
 	var namespaces []string
@@ -857,106 +1209,103 @@ func loadKubeConfig(filePath string) (*KubeConfig, error) {
 }
 
 func getCurrentContext() string {
-	kubeconfig := os.Getenv("KUBECONFIG")
-	if kubeconfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		kubeconfig = filepath.Join(homeDir, ".kube", "config")
-	}
+	return kubeconfigCurrentContext()
+}
 
-	config, err := loadKubeConfig(kubeconfig)
-	if err != nil {
-		return ""
+var listSortOrders = []string{"file", "name", "recent"}
+
+func validateListSort() error {
+	for _, order := range listSortOrders {
+		if listSort == order {
+			return nil
+		}
 	}
+	return fmt.Errorf("unknown --sort value '%s' (expected one of: %s)", listSort, strings.Join(listSortOrders, ", "))
+}
+
+// sortedContextNames returns all context names in --sort order; "recent"
+// puts pinned contexts first, then orders by frecency.
+func sortedContextNames(configInfos []ConfigInfo) []string {
+	names := allContextNames(configInfos)
+	switch listSort {
+	case "name":
+		sort.Strings(names)
+	case "recent":
+		loadStateOrEmpty().sortByUsage(names, time.Now())
+	}
+	return names
+}
 
-	return config.CurrentContext
+func listContextLine(configInfos []ConfigInfo, state *State, context, filePath string, showFile bool) {
+	marker := "  "
+	if context == currentContext {
+		marker = "🔹"
+	}
+	
+	prodIndicator := ""
+	if isProductionEnvironmentCombined(context, filePath) {
+		prodIndicator = " 🔴"
+	}
+	if state.isFavorite(context) {
+		prodIndicator += " ★"
+	}
+	
+	fileSuffix := ""
+	if showFile {
+		fileSuffix = " (" + filepath.Base(filePath) + ")"
+	}
+	
+	tagSuffix := ""
+	if tags := formatTags(contextTags(configInfos, context)); tags != "" {
+		tagSuffix = "  [" + tags + "]"
+	}
+	
+	fmt.Printf("%s %s%s%s%s\n", marker, context, fileSuffix, prodIndicator, tagSuffix)
 }
 
 func listAllContexts(configInfos []ConfigInfo) {
 	fmt.Printf("Available contexts from %s:\n\n", configDir)
+	state := loadStateOrEmpty()
 
-	for _, configInfo := range configInfos {
-		fileName := filepath.Base(configInfo.FilePath)
-		fmt.Printf("📁 %s:\n", fileName)
-
-		for _, context := range configInfo.Contexts {
-			marker := "  "
-			if context == currentContext {
-				marker = "🔹"
+	if groupByTag != "" {
+		groups, members := groupContextsByTag(configInfos, groupByTag)
+		for _, group := range groups {
+			if listSort == "recent" {
+				state.sortByUsage(members[group], time.Now())
 			}
-			
-			prodIndicator := ""
-			if isProductionEnvironmentCombined(context, configInfo.FilePath) {
-				prodIndicator = " 🔴"
+			fmt.Printf("🏷️  %s=%s:\n", groupByTag, group)
+			for _, context := range members[group] {
+				listContextLine(configInfos, state, context, findContextFile(configInfos, context), false)
 			}
-			
-			fmt.Printf("%s %s%s\n", marker, context, prodIndicator)
+			fmt.Println()
+		}
+	} else if listSort != "file" {
+		for _, context := range sortedContextNames(configInfos) {
+			listContextLine(configInfos, state, context, findContextFile(configInfos, context), true)
 		}
 		fmt.Println()
+	} else {
+		for _, configInfo := range configInfos {
+			fileName := filepath.Base(configInfo.FilePath)
+			fmt.Printf("📁 %s:\n", fileName)
+
+			for _, context := range configInfo.Contexts {
+				listContextLine(configInfos, state, context, configInfo.FilePath, false)
+			}
+			fmt.Println()
+		}
 	}
 	
 	fmt.Println("Legend:")
 	fmt.Println("🔹 = Current context")
 	fmt.Println("🔴 = Production environment (context name or config file)")
+	fmt.Println("★ = Pinned")
 }
 
-func interactiveContextSelect(configInfos []ConfigInfo) error {
-	var items []string
-	var contextMap = make(map[string]string)
-
-	for _, configInfo := range configInfos {
-		fileName := filepath.Base(configInfo.FilePath)
-		for _, context := range configInfo.Contexts {
-			prodIndicator := ""
-			if isProductionEnvironmentCombined(context, configInfo.FilePath) {
-				prodIndicator = " 🔴"
-			}
-			display := fmt.Sprintf("%s (%s)%s", context, fileName, prodIndicator)
-			items = append(items, display)
-			contextMap[display] = configInfo.FilePath
-		}
-	}
-
-	if len(items) == 0 {
-		return fmt.Errorf("no contexts available")
-	}
-
-	sort.Strings(items)
-
-	prompt := promptui.Select{
-		Label: "Select context (type to search/filter)",
-		Items: items,
-		Size:  10,
-		Searcher: func(input string, index int) bool {
-			item := items[index]
-			contextName := strings.Split(item, " (")[0]
-			
-			searchTarget := strings.Replace(strings.ToLower(contextName), " ", "", -1)
-			displayTarget := strings.Replace(strings.ToLower(item), " ", "", -1)
-			searchInput := strings.Replace(strings.ToLower(input), " ", "", -1)
-			
-			return strings.Contains(searchTarget, searchInput) || strings.Contains(displayTarget, searchInput)
-		},
-	}
-
-	_, result, err := prompt.Run()
-	if err != nil {
-		return err
-	}
-
-	contextName := strings.Split(result, " (")[0]
-	filePath := contextMap[result]
-
-	if isProductionEnvironmentCombined(contextName, filePath) {
-		showProductionWarning(contextName, filePath)
-	}
-
-	return setKubeConfig(filePath, contextName)
-}
-
-func interactiveNamespaceSelect(kubeconfig *KubeConfig) error {
-	namespaces, err := getLiveNamespaces()
+func interactiveNamespaceSelect(target NamespaceTarget) error {
+	namespaces, err := getLiveNamespaces(target)
 	if err != nil {
-		fmt.Printf("Warning: Could not get live namespaces (%v), using defaults\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Could not get live namespaces (%v), using defaults\n", err)
 		namespaces = []string{
 			"default",
 			"kube-system",
@@ -966,48 +1315,80 @@ func interactiveNamespaceSelect(kubeconfig *KubeConfig) error {
 	}
 
 	if len(namespaces) == 0 {
-		return fmt.Errorf("no namespaces found")
+		return notFoundError("no namespaces found")
 	}
 
-	prompt := promptui.Select{
+	picked, err := pick(PickRequest{
 		Label: "Select namespace (type to search/filter)",
-		Items: namespaces,
+		Items: plainPickerItems(namespaces),
 		Size:  15,
-		Searcher: func(input string, index int) bool {
-			namespace := namespaces[index]
-			searchTarget := strings.Replace(strings.ToLower(namespace), " ", "", -1)
-			searchInput := strings.Replace(strings.ToLower(input), " ", "", -1)
-			return strings.Contains(searchTarget, searchInput)
-		},
-	}
-
-	_, result, err := prompt.Run()
+	})
 	if err != nil {
 		return err
 	}
 
-	currentConfig := os.Getenv("KUBECONFIG")
-	if currentConfig == "" {
-		homeDir, _ := os.UserHomeDir()
-		currentConfig = filepath.Join(homeDir, ".kube", "config")
+	switchEntryPoint = entryPicker
+	return switchToNamespace(namespaces[picked.Index], target)
+}
+
+func allContextNames(configInfos []ConfigInfo) []string {
+	var names []string
+	for _, configInfo := range configInfos {
+		names = append(names, configInfo.Contexts...)
 	}
+	return names
+}
 
-	return switchToNamespace(result, kubeconfig, currentConfig)
+func findContextFile(configInfos []ConfigInfo, contextName string) string {
+	for _, configInfo := range configInfos {
+		for _, context := range configInfo.Contexts {
+			if context == contextName {
+				return configInfo.FilePath
+			}
+		}
+	}
+	return ""
 }
 
-func switchToContext(contextName string, configInfos []ConfigInfo) error {
+// switchToContext switches to contextName and, if namespace is not empty, to
+// that namespace in the same kubeconfig write.
+func switchToContext(contextName, namespace string, configInfos []ConfigInfo) error {
 	for _, configInfo := range configInfos {
 		for _, context := range configInfo.Contexts {
 			if context == contextName {
-				return setKubeConfig(configInfo.FilePath, contextName)
+				return setKubeConfig(configInfo.FilePath, contextName, namespace)
 			}
 		}
 	}
 
-	return fmt.Errorf("context '%s' not found", contextName)
+	return notFoundError("context '%s' not found", contextName)
 }
 
-func setKubeConfig(filePath, contextName string) error {
+// setKubeConfig makes contextName current in filePath. An empty namespace
+// restores the context's sticky namespace, if any.
+func setKubeConfig(filePath, contextName, namespace string) (err error) {
+	fileNamespace := contextNamespace(filePath, contextName)
+	setNamespace, restored := namespace, false
+	if setNamespace == "" {
+		setNamespace, restored = stickyNamespace(contextName), true
+	}
+	if setNamespace == fileNamespace {
+		setNamespace = ""
+	}
+	effectiveNamespace := fileNamespace
+	if setNamespace != "" {
+		effectiveNamespace = setNamespace
+	}
+	// The namespace that was asked for is logged, also when the switch fails.
+	defer func() {
+		auditSwitch("context", contextName, effectiveNamespace, filePath, err)
+	}()
+
+	hookTarget := newHookTarget("context", contextName, effectiveNamespace, filePath)
+	if err = runSwitchHooks("pre", hookTarget); err != nil {
+		return err
+	}
+
 	previousContext = currentContext
 
 	tempFile := "/tmp/kjx-config"
@@ -1015,7 +1396,7 @@ func setKubeConfig(filePath, contextName string) error {
 		tempFile = outputConfig
 	}
 	
-	err := ioutil.WriteFile(tempFile, []byte(filePath), 0644)
+	err = ioutil.WriteFile(tempFile, []byte(filePath), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write config path to file: %v", err)
 	}
@@ -1036,6 +1417,17 @@ func setKubeConfig(filePath, contextName string) error {
 
 	if configMap, ok := rawConfig.(map[interface{}]interface{}); ok {
 		configMap["current-context"] = contextName
+		if setNamespace != "" {
+			entry := findRawEntry(configMap, "contexts", contextName)
+			detail := rawEntryDetail(entry, "context")
+			if detail == nil && entry != nil {
+				detail = make(map[interface{}]interface{})
+				entry["context"] = detail
+			}
+			if detail != nil {
+				detail["namespace"] = setNamespace
+			}
+		}
 	}
 
 	data, err := yaml.Marshal(rawConfig)
@@ -1047,11 +1439,18 @@ func setKubeConfig(filePath, contextName string) error {
 		return err
 	}
 
+	recordContextUse(contextName)
 	fmt.Printf("Switched to context '%s' in %s\n", contextName, filepath.Base(filePath))
+	if namespace != "" {
+		recordContextNamespace(contextName, namespace)
+		fmt.Printf("📦 Switched to namespace '%s'\n", namespace)
+	} else if restored && setNamespace != "" {
+		fmt.Printf("📦 Restored namespace '%s'\n", setNamespace)
+	}
 	
 	if isProductionEnvironment(contextName) {
-		fmt.Println("🔴 You are now connected to a PRODUCTION environment!")
-		fmt.Println("🔴 Please be extra careful with your operations!")
+		fmt.Fprintln(os.Stderr, "🔴 You are now connected to a PRODUCTION environment!")
+		fmt.Fprintln(os.Stderr, "🔴 Please be extra careful with your operations!")
 	}
 	
 	if outputConfig == "" {
@@ -1059,57 +1458,46 @@ func setKubeConfig(filePath, contextName string) error {
 		fmt.Println("💡 Or use shell integration with: kjx install && source ~/.zshrc")
 	}
 	
-	return nil
+	return runSwitchHooks("post", hookTarget)
 }
 
-func switchToNamespace(namespace string, kubeconfig *KubeConfig, configPath string) error {
-	originalData, err := ioutil.ReadFile(configPath)
-	if err != nil {
+func switchToNamespace(namespace string, target NamespaceTarget) (err error) {
+	defer func() {
+		auditSwitch("namespace", target.Context, namespace, target.File, err)
+	}()
+
+	hookTarget := newHookTarget("namespace", target.Context, namespace, target.File)
+	if err = runSwitchHooks("pre", hookTarget); err != nil {
 		return err
 	}
 
-	var rawConfig interface{}
-	if err := yaml.Unmarshal(originalData, &rawConfig); err != nil {
+	configMap, err := loadRawKubeConfig(target.File)
+	if err != nil {
 		return err
 	}
 
-	if configMap, ok := rawConfig.(map[interface{}]interface{}); ok {
-		if contextsInterface, exists := configMap["contexts"]; exists {
-			if contexts, ok := contextsInterface.([]interface{}); ok {
-				for _, ctxInterface := range contexts {
-					if ctx, ok := ctxInterface.(map[interface{}]interface{}); ok {
-						if nameInterface, exists := ctx["name"]; exists {
-							if name, ok := nameInterface.(string); ok && name == kubeconfig.CurrentContext {
-								if contextDetailInterface, exists := ctx["context"]; exists {
-									if contextDetail, ok := contextDetailInterface.(map[interface{}]interface{}); ok {
-										contextDetail["namespace"] = namespace
-									}
-								}
-								break
-							}
-						}
-					}
-				}
-			}
-		}
+	entry := findRawEntry(configMap, "contexts", target.Context)
+	if entry == nil {
+		return notFoundError("context '%s' not found in %s", target.Context, target.File)
 	}
-
-	data, err := yaml.Marshal(rawConfig)
-	if err != nil {
-		return err
+	detail := rawEntryDetail(entry, "context")
+	if detail == nil {
+		detail = make(map[interface{}]interface{})
+		entry["context"] = detail
 	}
+	detail["namespace"] = namespace
 
-	if err := ioutil.WriteFile(configPath, data, 0644); err != nil {
+	if err := saveRawKubeConfig(target.File, configMap, 0644); err != nil {
 		return err
 	}
 
-	fmt.Printf("Switched to namespace '%s'\n", namespace)
+	recordContextNamespace(target.Context, namespace)
+	fmt.Printf("Switched to namespace '%s' in context '%s'\n", namespace, target.Context)
 	
-	contextName := kubeconfig.CurrentContext
-	if isProductionEnvironment(contextName) {
-		fmt.Printf("🔴 You are working in namespace '%s' in a PRODUCTION environment!\n", namespace)
-		fmt.Println("🔴 Please be extra careful with your operations!")
+	if isProductionEnvironment(target.Context) {
+		fmt.Fprintf(os.Stderr, "🔴 You are working in namespace '%s' in a PRODUCTION environment!\n", namespace)
+		fmt.Fprintln(os.Stderr, "🔴 Please be extra careful with your operations!")
 	}
 	
-	return nil
+	return runSwitchHooks("post", hookTarget)
 }
\ No newline at end of file
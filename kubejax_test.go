@@ -0,0 +1,256 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const fullKubeConfig = `apiVersion: v1
+kind: Config
+preferences:
+  colors: true
+  extensions:
+  - name: pref-ext
+    extension:
+      theme: dark
+clusters:
+- name: prod
+  cluster:
+    certificate-authority-data: Q0EgREFUQQ==
+    server: https://prod.example.com:6443
+    tls-server-name: api.prod.internal
+    proxy-url: socks5://proxy.example.com:1080
+    disable-compression: true
+    extensions:
+    - name: cluster-ext
+      extension:
+        region: eu-west-1
+    future-cluster-field: kept
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+    namespace: payments
+    extensions:
+    - name: context-ext
+      extension:
+        owner: team-a
+    future-context-field: kept
+current-context: prod
+users:
+- name: admin
+  user:
+    tokenFile: /var/run/secrets/token
+    as: jane
+    as-uid: "1000"
+    as-groups:
+    - system:masters
+    - ops
+    as-user-extra:
+      scopes:
+      - read
+      - write
+    future-user-field: kept
+- name: sso
+  user:
+    auth-provider:
+      name: oidc
+      config:
+        client-id: kubejax
+        idp-issuer-url: https://sso.example.com
+      future-provider-field: kept
+- name: eks
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args:
+      - eks
+      - get-token
+      env:
+      - name: AWS_PROFILE
+        value: prod
+        future-env-field: kept
+      installHint: install the aws cli
+      provideClusterInfo: true
+      interactiveMode: IfAvailable
+      future-exec-field: kept
+extensions:
+- name: top-ext
+  extension:
+    managed-by: kubejax
+future-top-level-field:
+  nested:
+    value: kept
+`
+
+func TestKubeConfigRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(fullKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadKubeConfig(path)
+	if err != nil {
+		t.Fatalf("loadKubeConfig: %v", err)
+	}
+	saved, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var want, got interface{}
+	if err := yaml.Unmarshal([]byte(fullKubeConfig), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(saved, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip changed the config:\n%s", saved)
+	}
+}
+
+func TestKubeConfigTypedFields(t *testing.T) {
+	var config KubeConfig
+	if err := yaml.Unmarshal([]byte(fullKubeConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := config.Clusters[0].Cluster
+	if cluster.TLSServerName != "api.prod.internal" || cluster.ProxyURL != "socks5://proxy.example.com:1080" || !cluster.DisableCompression {
+		t.Errorf("cluster fields not loaded: %+v", cluster)
+	}
+	if len(cluster.Extensions) != 1 || cluster.Extensions[0].Name != "cluster-ext" {
+		t.Errorf("cluster extensions not loaded: %+v", cluster.Extensions)
+	}
+
+	admin := config.Users[0].User
+	if admin.TokenFile != "/var/run/secrets/token" || admin.Impersonate != "jane" {
+		t.Errorf("user fields not loaded: %+v", admin)
+	}
+	if !reflect.DeepEqual(admin.ImpersonateGroups, []string{"system:masters", "ops"}) {
+		t.Errorf("as-groups = %v", admin.ImpersonateGroups)
+	}
+	if !reflect.DeepEqual(admin.ImpersonateUserExtra["scopes"], []string{"read", "write"}) {
+		t.Errorf("as-user-extra = %v", admin.ImpersonateUserExtra)
+	}
+
+	provider := config.Users[1].User.AuthProvider
+	if provider == nil || provider.Name != "oidc" || provider.Config["client-id"] != "kubejax" {
+		t.Errorf("auth-provider not loaded: %+v", provider)
+	} else if provider.Extra["future-provider-field"] != "kept" {
+		t.Errorf("auth-provider extra = %v", provider.Extra)
+	}
+
+	exec := config.Users[2].User.Exec
+	if exec == nil || exec.Command != "aws" || len(exec.Env) != 1 || exec.Env[0].Value != "prod" {
+		t.Errorf("exec not loaded: %+v", exec)
+	} else if exec.Env[0].Extra["future-env-field"] != "kept" {
+		t.Errorf("exec env extra = %v", exec.Env[0].Extra)
+	}
+
+	if len(config.Extensions) != 1 || config.Extensions[0].Name != "top-ext" {
+		t.Errorf("top-level extensions not loaded: %+v", config.Extensions)
+	}
+	if _, ok := config.Extra["future-top-level-field"]; !ok {
+		t.Errorf("unknown top-level key dropped: %v", config.Extra)
+	}
+}
+
+// writeKubeconfigPathList writes a.yaml, which has no current-context, and
+// b.yaml, which sets beta, and points KUBECONFIG at both.
+func writeKubeconfigPathList(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-a
+  cluster:
+    server: https://a.example.com
+contexts:
+- name: alpha
+  context:
+    cluster: cluster-a
+    user: user-a
+users:
+- name: user-a
+  user:
+    token: a
+`,
+		"b.yaml": `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-b
+  cluster:
+    server: https://b.example.com
+contexts:
+- name: beta
+  context:
+    cluster: cluster-b
+    user: user-b
+    namespace: team
+current-context: beta
+users:
+- name: user-b
+  user:
+    token: b
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "a.yaml")+string(os.PathListSeparator)+filepath.Join(dir, "b.yaml"))
+	t.Setenv("KJX_HOME", t.TempDir())
+	return dir
+}
+
+func TestCurrentContextWithKubeconfigPathList(t *testing.T) {
+	dir := writeKubeconfigPathList(t)
+
+	if got := getCurrentContext(); got != "beta" {
+		t.Errorf("getCurrentContext() = %q, want beta", got)
+	}
+
+	contextName, configFile, clusterName, namespace := getCurrentContextInfo()
+	if contextName != "beta" || configFile != filepath.Join(dir, "b.yaml") || clusterName != "cluster-b" || namespace != "team" {
+		t.Errorf("getCurrentContextInfo() = %q, %q, %q, %q", contextName, configFile, clusterName, namespace)
+	}
+}
+
+func TestPrintCurrentContextWithKubeconfigPathList(t *testing.T) {
+	dir := writeKubeconfigPathList(t)
+
+	defer func(format string, stdout *os.File) {
+		outputFormat, os.Stdout = format, stdout
+	}(outputFormat, os.Stdout)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputFormat, os.Stdout = "json", writer
+
+	printErr := printCurrentContext()
+	writer.Close()
+	output, _ := ioutil.ReadAll(reader)
+	if printErr != nil {
+		t.Fatalf("printCurrentContext: %v", printErr)
+	}
+
+	var record ContextRecord
+	if err := yaml.Unmarshal(output, &record); err != nil {
+		t.Fatalf("parsing %s: %v", output, err)
+	}
+	if record.Name != "beta" || record.File != filepath.Join(dir, "b.yaml") || record.Namespace != "team" || !record.Current {
+		t.Errorf("printCurrentContext() printed %+v", record)
+	}
+}
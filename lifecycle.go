@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	lifecycleGC     bool
+	copyTargetFile  string
+	copyContextName string
+)
+
+// removeUnreferenced drops clusters and users that no context in configMap
+// refers to anymore and returns their names.
+func removeUnreferenced(configMap map[interface{}]interface{}) (clusters, users []string) {
+	usedClusters := make(map[string]bool)
+	usedUsers := make(map[string]bool)
+	for _, entry := range rawNamedEntries(configMap, "contexts") {
+		detail := rawEntryDetail(entry, "context")
+		usedClusters[rawString(detail, "cluster")] = true
+		usedUsers[rawString(detail, "user")] = true
+	}
+
+	prune := func(key string, used map[string]bool) []string {
+		var kept []map[interface{}]interface{}
+		var removed []string
+		for _, entry := range rawNamedEntries(configMap, key) {
+			if used[rawEntryName(entry)] {
+				kept = append(kept, entry)
+			} else {
+				removed = append(removed, rawEntryName(entry))
+			}
+		}
+		if removed != nil {
+			setRawNamedEntries(configMap, key, kept)
+		}
+		return removed
+	}
+
+	return prune("clusters", usedClusters), prune("users", usedUsers)
+}
+
+func reportGarbageCollected(clusters, users []string) {
+	for _, name := range clusters {
+		fmt.Printf("🗑️  Removed unreferenced cluster '%s'\n", name)
+	}
+	for _, name := range users {
+		fmt.Printf("🗑️  Removed unreferenced user '%s'\n", name)
+	}
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	filePath := findContextFile(configInfos, oldName)
+	if filePath == "" {
+		return notFoundError("context '%s' not found", oldName)
+	}
+	if existing := findContextFile(configInfos, newName); existing != "" {
+		return fmt.Errorf("context '%s' already exists in %s", newName, filepath.Base(existing))
+	}
+
+	if err := confirmProductionAction("rename", oldName, filePath); err != nil {
+		return err
+	}
+
+	rawConfig, err := loadRawKubeConfig(filePath)
+	if err != nil {
+		return err
+	}
+
+	contextEntry := findRawEntry(rawConfig, "contexts", oldName)
+	if contextEntry == nil {
+		return notFoundError("context '%s' not found in %s", oldName, filepath.Base(filePath))
+	}
+	contextEntry["name"] = newName
+
+	if rawString(rawConfig, "current-context") == oldName {
+		rawConfig["current-context"] = newName
+	}
+
+	if err := saveRawKubeConfig(filePath, rawConfig, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Renamed context '%s' to '%s' in %s\n", oldName, newName, filepath.Base(filePath))
+	if err := renameContextMetadata(oldName, newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not move tags of '%s': %v\n", oldName, err)
+	}
+	if err := renameContextState(oldName, newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not move pin and usage of '%s': %v\n", oldName, err)
+	}
+	return nil
+}
+
+func runDelete(cmd *cobra.Command, args []string) error {
+	contextName := args[0]
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	filePath := findContextFile(configInfos, contextName)
+	if filePath == "" {
+		return notFoundError("context '%s' not found", contextName)
+	}
+
+	if err := confirmProductionAction("delete", contextName, filePath); err != nil {
+		return err
+	}
+
+	rawConfig, err := loadRawKubeConfig(filePath)
+	if err != nil {
+		return err
+	}
+
+	var kept []map[interface{}]interface{}
+	for _, entry := range rawNamedEntries(rawConfig, "contexts") {
+		if rawEntryName(entry) != contextName {
+			kept = append(kept, entry)
+		}
+	}
+	setRawNamedEntries(rawConfig, "contexts", kept)
+
+	if rawString(rawConfig, "current-context") == contextName {
+		rawConfig["current-context"] = ""
+		fmt.Fprintf(os.Stderr, "Warning: '%s' was the current context of %s; current-context is now unset\n", contextName, filepath.Base(filePath))
+	}
+
+	var removedClusters, removedUsers []string
+	if lifecycleGC {
+		removedClusters, removedUsers = removeUnreferenced(rawConfig)
+	}
+
+	if err := saveRawKubeConfig(filePath, rawConfig, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Deleted context '%s' from %s\n", contextName, filepath.Base(filePath))
+	reportGarbageCollected(removedClusters, removedUsers)
+	if err := renameContextMetadata(contextName, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not remove tags of '%s': %v\n", contextName, err)
+	}
+	if err := renameContextState(contextName, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not remove pin and usage of '%s': %v\n", contextName, err)
+	}
+	return nil
+}
+
+func resolveTargetFile(target string) string {
+	if filepath.IsAbs(target) || filepath.Dir(target) != "." {
+		return target
+	}
+	return filepath.Join(configDir, target)
+}
+
+func sameFilePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func runCopy(cmd *cobra.Command, args []string) error {
+	contextName := args[0]
+	newName := copyContextName
+	if newName == "" {
+		newName = contextName
+	}
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	sourcePath := findContextFile(configInfos, contextName)
+	if sourcePath == "" {
+		return notFoundError("context '%s' not found", contextName)
+	}
+
+	targetPath := resolveTargetFile(copyTargetFile)
+	sameFile := sameFilePath(sourcePath, targetPath)
+	if sameFile && newName == contextName {
+		return fmt.Errorf("copying '%s' into its own file needs a new name (--name)", contextName)
+	}
+
+	if err := confirmProductionAction("copy", contextName, sourcePath); err != nil {
+		return err
+	}
+
+	sourceConfig, err := loadRawKubeConfig(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	var copied standaloneBuilder
+	if err := copied.add(sourceConfig, sourcePath, contextName, newName); err != nil {
+		return err
+	}
+
+	targetConfig := newRawKubeConfig()
+	targetConfig["current-context"] = newName
+	if _, err := os.Stat(targetPath); err == nil {
+		if targetConfig, err = loadRawKubeConfig(targetPath); err != nil {
+			return err
+		}
+	}
+
+	if findRawEntry(targetConfig, "contexts", newName) != nil {
+		return fmt.Errorf("context '%s' already exists in %s (use --name)", newName, filepath.Base(targetPath))
+	}
+
+	clusters := rawNamedEntries(targetConfig, "clusters")
+	users := rawNamedEntries(targetConfig, "users")
+	contextEntry := copied.contexts[0]
+	contextDetail := rawEntryDetail(contextEntry, "context")
+
+	var clusterName string
+	clusters, clusterName = addUniqueEntry(clusters, copied.clusters[0])
+	contextDetail["cluster"] = clusterName
+	if len(copied.users) > 0 {
+		var userName string
+		users, userName = addUniqueEntry(users, copied.users[0])
+		contextDetail["user"] = userName
+	}
+
+	setRawNamedEntries(targetConfig, "clusters", clusters)
+	setRawNamedEntries(targetConfig, "users", users)
+	setRawNamedEntries(targetConfig, "contexts", append(rawNamedEntries(targetConfig, "contexts"), contextEntry))
+
+	if err := saveRawKubeConfig(targetPath, targetConfig, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Copied context '%s' from %s to %s as '%s'\n", contextName, filepath.Base(sourcePath), filepath.Base(targetPath), newName)
+	if newName == contextName && sameFilePath(filepath.Dir(targetPath), configDir) {
+		fmt.Fprintf(os.Stderr, "Warning: '%s' is now defined in more than one file; kjx uses the first one it finds\n", newName)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// metadataFileName is the kjx sidecar in the config directory. It starts with
+// a dot so loadAllKubeConfigs never mistakes it for a kubeconfig.
+const metadataFileName = ".kjx-metadata.yaml"
+
+// metadataExtensionName is the context extension kjx reads tags from, so
+// metadata can also ship inside the kubeconfig files themselves.
+const metadataExtensionName = "kjx"
+
+var wellKnownTags = []string{"team", "region", "cloud", "owner", "runbook"}
+
+var (
+	tagFilters []string
+	groupByTag string
+)
+
+type MetadataFile struct {
+	Contexts map[string]map[string]string `yaml:"contexts"`
+}
+
+func metadataFilePath() string {
+	return filepath.Join(configDir, metadataFileName)
+}
+
+func loadMetadataFile() (*MetadataFile, error) {
+	metadata := &MetadataFile{Contexts: make(map[string]map[string]string)}
+
+	data, err := ioutil.ReadFile(metadataFilePath())
+	if os.IsNotExist(err) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", metadataFileName, err)
+	}
+	if metadata.Contexts == nil {
+		metadata.Contexts = make(map[string]map[string]string)
+	}
+
+	return metadata, nil
+}
+
+func saveMetadataFile(metadata *MetadataFile) error {
+	data, err := yaml.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(metadataFilePath(), data, 0644)
+}
+
+// extensionTags reads tags from a context's "kjx" extension.
+func extensionTags(detail ContextDetail) map[string]string {
+	tags := make(map[string]string)
+	for _, extension := range detail.Extensions {
+		if extension.Name != metadataExtensionName {
+			continue
+		}
+		values, ok := extension.Extension.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range values {
+			tags[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return tags
+}
+
+// mergedContextTags combines extension tags with the sidecar; the sidecar
+// wins because it is what `kjx tag` edits.
+func mergedContextTags(detail ContextDetail, contextName string, metadata *MetadataFile) map[string]string {
+	tags := extensionTags(detail)
+	if metadata != nil {
+		for key, value := range metadata.Contexts[contextName] {
+			tags[key] = value
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+func contextTags(configInfos []ConfigInfo, contextName string) map[string]string {
+	for _, configInfo := range configInfos {
+		if tags, ok := configInfo.Tags[contextName]; ok {
+			return tags
+		}
+	}
+	return nil
+}
+
+// parseTagFilters turns "key=value" (or bare "key") flags into a filter map.
+// A bare key matches any context that has the tag at all.
+func parseTagFilters(filters []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, filter := range filters {
+		key, value := filter, ""
+		if i := strings.Index(filter, "="); i >= 0 {
+			key, value = filter[:i], filter[i+1:]
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag filter '%s' (expected key=value)", filter)
+		}
+		parsed[key] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+func matchesTags(tags map[string]string, filters map[string]string) bool {
+	for key, want := range filters {
+		have, ok := tags[key]
+		if !ok {
+			return false
+		}
+		if want != "" && !strings.EqualFold(have, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterConfigInfosByTags keeps only the contexts whose tags match every
+// --tag filter; files left without contexts are dropped.
+func filterConfigInfosByTags(configInfos []ConfigInfo, filters map[string]string) []ConfigInfo {
+	if len(filters) == 0 {
+		return configInfos
+	}
+
+	var filtered []ConfigInfo
+	for _, configInfo := range configInfos {
+		var contexts []string
+		for _, context := range configInfo.Contexts {
+			if matchesTags(configInfo.Tags[context], filters) {
+				contexts = append(contexts, context)
+			}
+		}
+		if len(contexts) > 0 {
+			configInfo.Contexts = contexts
+			filtered = append(filtered, configInfo)
+		}
+	}
+	return filtered
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatTags renders tags as "key=value" pairs, leaving out the runbook URL
+// which is too long for list views.
+func formatTags(tags map[string]string) string {
+	var parts []string
+	for _, key := range sortedTagKeys(tags) {
+		if key == "runbook" {
+			continue
+		}
+		parts = append(parts, key+"="+tags[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func showContextMetadata(tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	labels := map[string]string{
+		"team":   "👥 Team",
+		"region": "🌍 Region",
+		"cloud":  "☁️  Cloud",
+		"owner":  "👤 Owner",
+	}
+	for _, key := range wellKnownTags {
+		if label, ok := labels[key]; ok && tags[key] != "" {
+			fmt.Printf("%s: %s\n", label, tags[key])
+		}
+	}
+
+	var other []string
+	for _, key := range sortedTagKeys(tags) {
+		if _, known := labels[key]; !known && key != "runbook" {
+			other = append(other, key+"="+tags[key])
+		}
+	}
+	if len(other) > 0 {
+		fmt.Printf("🏷️  Tags: %s\n", strings.Join(other, ", "))
+	}
+	if tags["runbook"] != "" {
+		fmt.Printf("📖 Runbook: %s\n", tags["runbook"])
+	}
+}
+
+// groupContextsByTag orders context names by the value they are grouped
+// under (see contextGroupValue); contexts without the tag go last.
+func groupContextsByTag(configInfos []ConfigInfo, key string) (groups []string, members map[string][]string) {
+	members = make(map[string][]string)
+	for _, configInfo := range configInfos {
+		for _, context := range configInfo.Contexts {
+			value := contextGroupValue(configInfo, context, key)
+			if _, ok := members[value]; !ok {
+				groups = append(groups, value)
+			}
+			members[value] = append(members[value], context)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i] == "(none)" || groups[j] == "(none)" {
+			return groups[j] == "(none)" && groups[i] != "(none)"
+		}
+		return groups[i] < groups[j]
+	})
+	for _, group := range groups {
+		sort.Strings(members[group])
+	}
+	return groups, members
+}
+
+func showCurrentContextMetadata(kubeconfigPath, contextName string) {
+	kubeconfig, err := loadKubeConfig(kubeconfigPath)
+	if err != nil {
+		return
+	}
+	detail, _ := findContextDetail(kubeconfig, contextName)
+	metadata, _ := loadMetadataFile()
+	showContextMetadata(mergedContextTags(detail, contextName, metadata))
+}
+
+func renameContextMetadata(oldName, newName string) error {
+	metadata, err := loadMetadataFile()
+	if err != nil {
+		return err
+	}
+	tags, ok := metadata.Contexts[oldName]
+	if !ok {
+		return nil
+	}
+	delete(metadata.Contexts, oldName)
+	if newName != "" {
+		metadata.Contexts[newName] = tags
+	}
+	return saveMetadataFile(metadata)
+}
+
+// runTag sets ("key=value") or removes ("key-") tags of a context, or shows
+// them when no changes are given.
+func runTag(cmd *cobra.Command, args []string) error {
+	contextName := args[0]
+
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+	if findContextFile(configInfos, contextName) == "" {
+		return notFoundError("context '%s' not found", contextName)
+	}
+
+	if len(args) == 1 {
+		tags := contextTags(configInfos, contextName)
+		if len(tags) == 0 {
+			fmt.Printf("Context '%s' has no tags\n", contextName)
+			return nil
+		}
+		for _, key := range sortedTagKeys(tags) {
+			fmt.Printf("%s=%s\n", key, tags[key])
+		}
+		return nil
+	}
+
+	metadata, err := loadMetadataFile()
+	if err != nil {
+		return err
+	}
+	tags := metadata.Contexts[contextName]
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+
+	for _, change := range args[1:] {
+		if strings.HasSuffix(change, "-") && !strings.Contains(change, "=") {
+			delete(tags, strings.TrimSuffix(change, "-"))
+			continue
+		}
+		i := strings.Index(change, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid tag '%s' (expected key=value or key- to remove)", change)
+		}
+		tags[change[:i]] = change[i+1:]
+	}
+
+	if len(tags) == 0 {
+		delete(metadata.Contexts, contextName)
+	} else {
+		metadata.Contexts[contextName] = tags
+	}
+
+	if err := saveMetadataFile(metadata); err != nil {
+		return err
+	}
+
+	fmt.Printf("🏷️  Updated tags of '%s': %s\n", contextName, formatTags(tags))
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// NamespaceTarget is the context a namespace switch applies to and the
+// kubeconfig file that defines it.
+type NamespaceTarget struct {
+	Context string
+	File    string
+	// Outside is set when File is not part of $KUBECONFIG, so kubectl has to
+	// be pointed at it explicitly.
+	Outside bool
+}
+
+// kubeconfigPaths splits $KUBECONFIG into its files, the way kubectl does.
+func kubeconfigPaths() []string {
+	var paths []string
+	for _, path := range filepath.SplitList(currentKubeconfigPath()) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// kubeconfigCurrentContext returns the current context kubectl would use:
+// the first current-context set in the files of $KUBECONFIG.
+func kubeconfigCurrentContext() string {
+	for _, path := range kubeconfigPaths() {
+		if kubeconfig, err := loadKubeConfig(path); err == nil && kubeconfig.CurrentContext != "" {
+			return kubeconfig.CurrentContext
+		}
+	}
+	return ""
+}
+
+// resolveNamespaceTarget finds the file that defines contextName, or the
+// current context when contextName is empty. Like kubectl, the first
+// current-context and the first definition in $KUBECONFIG win; contexts that
+// are not in $KUBECONFIG are looked up in the config directory.
+func resolveNamespaceTarget(contextName string) (NamespaceTarget, error) {
+	paths := kubeconfigPaths()
+
+	if contextName == "" {
+		if contextName = kubeconfigCurrentContext(); contextName == "" {
+			return NamespaceTarget{}, notFoundError("no current context set in %s (use --context)", strings.Join(paths, string(os.PathListSeparator)))
+		}
+	}
+
+	for _, path := range paths {
+		kubeconfig, err := loadKubeConfig(path)
+		if err != nil {
+			continue
+		}
+		if _, ok := findContextDetail(kubeconfig, contextName); ok {
+			return NamespaceTarget{Context: contextName, File: path}, nil
+		}
+	}
+
+	if configInfos, err := loadAllKubeConfigs(); err == nil {
+		if filePath := findContextFile(configInfos, contextName); filePath != "" {
+			return NamespaceTarget{Context: contextName, File: filePath, Outside: true}, nil
+		}
+	}
+
+	return NamespaceTarget{}, notFoundError("context '%s' not found in KUBECONFIG or %s", contextName, configDir)
+}
+
+// kubectlArgs selects the target's context (and file) for a kubectl call.
+func (t NamespaceTarget) kubectlArgs() []string {
+	args := []string{"--context", t.Context}
+	if t.Outside {
+		args = append(args, "--kubeconfig", t.File)
+	}
+	return args
+}
+
+// namespaceCacheTTL is how long cached namespace lists are trusted before the
+// cluster is asked again.
+const namespaceCacheTTL = 10 * time.Minute
+
+// NamespaceCache holds the namespaces last fetched per context, kept in
+// namespaces.yaml in the kjx home directory.
+type NamespaceCache struct {
+	Contexts map[string]CachedNamespaces `yaml:"contexts,omitempty"`
+}
+
+type CachedNamespaces struct {
+	Namespaces []string  `yaml:"namespaces"`
+	FetchedAt  time.Time `yaml:"fetchedAt"`
+}
+
+func namespaceCachePath() string {
+	return filepath.Join(kjxHomeDir(), "namespaces.yaml")
+}
+
+func loadNamespaceCache() *NamespaceCache {
+	cache := &NamespaceCache{}
+	data, err := ioutil.ReadFile(namespaceCachePath())
+	if err != nil {
+		return cache
+	}
+	if err := yaml.Unmarshal(data, cache); err != nil {
+		// The cache is only an optimization; a broken one is started over.
+		return &NamespaceCache{}
+	}
+	return cache
+}
+
+func saveNamespaceCache(cache *NamespaceCache) error {
+	data, err := yaml.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(kjxHomeDir(), 0700); err != nil {
+		return err
+	}
+	return writeConfigFile(namespaceCachePath(), data, 0600)
+}
+
+// fresh returns the cached namespaces of a context if they are recent enough.
+func (c *NamespaceCache) fresh(contextName string) ([]string, bool) {
+	cached, ok := c.Contexts[contextName]
+	if !ok || time.Since(cached.FetchedAt) >= namespaceCacheTTL {
+		return nil, false
+	}
+	return cached.Namespaces, true
+}
+
+// storeCachedNamespaces caches freshly fetched namespaces, keyed by context.
+func storeCachedNamespaces(fetched map[string][]string) {
+	cache := loadNamespaceCache()
+	if cache.Contexts == nil {
+		cache.Contexts = make(map[string]CachedNamespaces)
+	}
+	now := time.Now()
+	for contextName, namespaces := range fetched {
+		cache.Contexts[contextName] = CachedNamespaces{Namespaces: namespaces, FetchedAt: now}
+	}
+	if err := saveNamespaceCache(cache); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not cache namespaces: %v\n", err)
+	}
+}
+
+// fetchNamespaces asks the cluster for its namespaces and caches the answer.
+func fetchNamespaces(target NamespaceTarget) ([]string, error) {
+	namespaces, err := getLiveNamespaces(target)
+	if err != nil {
+		return nil, err
+	}
+	storeCachedNamespaces(map[string][]string{target.Context: namespaces})
+	return namespaces, nil
+}
+
+// cachedNamespaces returns the target's namespaces from the cache while it is
+// fresh and from the cluster otherwise.
+func cachedNamespaces(target NamespaceTarget) ([]string, error) {
+	if namespaces, ok := loadNamespaceCache().fresh(target.Context); ok {
+		return namespaces, nil
+	}
+	return fetchNamespaces(target)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNamespace checks that namespace exists in the target's cluster. A
+// cached list can confirm a namespace, but only the cluster can rule one out.
+// When the cluster cannot be reached the switch goes ahead with a warning.
+func validateNamespace(target NamespaceTarget, namespace string) error {
+	if namespaces, ok := loadNamespaceCache().fresh(target.Context); ok && containsString(namespaces, namespace) {
+		return nil
+	}
+
+	namespaces, err := fetchNamespaces(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not verify namespace exists: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Switching to namespace '%s' anyway...\n", namespace)
+		return nil
+	}
+	if !containsString(namespaces, namespace) {
+		fmt.Fprintf(os.Stderr, "Available namespaces: %s\n", strings.Join(namespaces, ", "))
+		return notFoundError("namespace '%s' not found in context '%s'", namespace, target.Context)
+	}
+	return nil
+}
+
+// splitTargetTerm splits "context/namespace" or "context:namespace" at the
+// last separator.
+func splitTargetTerm(term string) (contextPart, namespacePart string, ok bool) {
+	i := strings.LastIndex(term, "/")
+	if i < 0 {
+		i = strings.LastIndex(term, ":")
+	}
+	if i <= 0 || i == len(term)-1 {
+		return term, "", false
+	}
+	return term[:i], term[i+1:], true
+}
+
+// searchTargetNamespace resolves the namespace part of a "context/namespace"
+// search term: an exact name wins, then a unique fuzzy match or the best one
+// with --first. Otherwise the user picks on a terminal.
+func searchTargetNamespace(target NamespaceTarget, term string) (string, error) {
+	namespaces, err := cachedNamespaces(target)
+	if err != nil {
+		return "", err
+	}
+	if containsString(namespaces, term) {
+		return term, nil
+	}
+
+	matches := searchNamespaces(namespaces, term)
+	switch {
+	case len(matches) == 0:
+		return "", notFoundError("no namespaces in '%s' match '%s'", target.Context, term)
+	case len(matches) == 1 || searchFirst:
+		return matches[0], nil
+	case stdinIsTerminal():
+		picked, err := pick(PickRequest{
+			Label: fmt.Sprintf("Select from namespaces in '%s' matching '%s' (type to filter)", target.Context, term),
+			Items: plainPickerItems(matches),
+			Size:  15,
+		})
+		if err != nil {
+			return "", err
+		}
+		return matches[picked.Index], nil
+	}
+
+	fmt.Printf("\nNamespaces in '%s' matching '%s':\n", target.Context, term)
+	for i, match := range matches {
+		fmt.Printf("%d) %s\n", i+1, match)
+	}
+	return "", ambiguousError("%d namespaces match '%s'; refine the search term or use --first", len(matches), term)
+}
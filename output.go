@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+var outputFormat string
+
+var outputFormats = []string{"json", "yaml", "wide", "name"}
+
+// ContextRecord is the machine-readable view of a context. Field names are
+// part of kjx's output contract; add new fields rather than renaming these.
+type ContextRecord struct {
+	Name      string            `json:"name" yaml:"name"`
+	Cluster   string            `json:"cluster" yaml:"cluster"`
+	Server    string            `json:"server" yaml:"server"`
+	User      string            `json:"user" yaml:"user"`
+	Namespace string            `json:"namespace" yaml:"namespace"`
+	File      string            `json:"file" yaml:"file"`
+	Tier      string            `json:"tier" yaml:"tier"`
+	Current   bool              `json:"current" yaml:"current"`
+	Tags      map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+type NamespaceRecord struct {
+	Name    string `json:"name" yaml:"name"`
+	Context string `json:"context" yaml:"context"`
+	Current bool   `json:"current" yaml:"current"`
+}
+
+func validateOutputFormat() error {
+	if outputFormat == "" {
+		return nil
+	}
+	for _, format := range outputFormats {
+		if outputFormat == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format '%s' (expected one of: json, yaml, wide, name)", outputFormat)
+}
+
+func buildContextRecord(kubeconfig *KubeConfig, contextName, filePath string) ContextRecord {
+	record := ContextRecord{
+		Name:    contextName,
+		File:    filePath,
+		Tier:    environmentTier(contextName, filePath),
+		Current: contextName == currentContext,
+	}
+
+	if kubeconfig == nil {
+		return record
+	}
+
+	if detail, ok := findContextDetail(kubeconfig, contextName); ok {
+		record.Cluster = detail.Cluster
+		record.User = detail.User
+		record.Namespace = detail.Namespace
+		for _, cluster := range kubeconfig.Clusters {
+			if cluster.Name == detail.Cluster {
+				record.Server = cluster.Cluster.Server
+				break
+			}
+		}
+	}
+	if record.Namespace == "" {
+		record.Namespace = "default"
+	}
+
+	return record
+}
+
+// buildContextRecords returns records for contextNames in the given order.
+func buildContextRecords(configInfos []ConfigInfo, contextNames []string) []ContextRecord {
+	loaded := make(map[string]*KubeConfig)
+
+	var records []ContextRecord
+	for _, name := range contextNames {
+		filePath := findContextFile(configInfos, name)
+		kubeconfig, ok := loaded[filePath]
+		if !ok {
+			kubeconfig, _ = loadKubeConfig(filePath)
+			loaded[filePath] = kubeconfig
+		}
+		record := buildContextRecord(kubeconfig, name, filePath)
+		record.Tags = contextTags(configInfos, name)
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func printStructured(value interface{}) error {
+	switch outputFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case "yaml":
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return fmt.Errorf("unsupported structured output format '%s'", outputFormat)
+}
+
+func printContextRecords(records []ContextRecord) error {
+	switch outputFormat {
+	case "json", "yaml":
+		if records == nil {
+			records = []ContextRecord{}
+		}
+		return printStructured(records)
+	case "name":
+		for _, record := range records {
+			fmt.Println(record.Name)
+		}
+	case "wide":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tCLUSTER\tSERVER\tUSER\tNAMESPACE\tFILE\tTIER")
+		for _, record := range records {
+			marker := ""
+			if record.Current {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", marker, record.Name, record.Cluster,
+				record.Server, record.User, record.Namespace, record.File, record.Tier)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func printCurrentContextRecord(record ContextRecord) error {
+	switch outputFormat {
+	case "json", "yaml":
+		return printStructured(record)
+	}
+	return printContextRecords([]ContextRecord{record})
+}
+
+func printNamespaceRecords(records []NamespaceRecord) error {
+	switch outputFormat {
+	case "json", "yaml":
+		if records == nil {
+			records = []NamespaceRecord{}
+		}
+		return printStructured(records)
+	case "name":
+		for _, record := range records {
+			fmt.Println(record.Name)
+		}
+	case "wide":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tCONTEXT")
+		for _, record := range records {
+			marker := ""
+			if record.Current {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", marker, record.Name, record.Context)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func printCurrentContext() error {
+	target, err := resolveNamespaceTarget("")
+	if err != nil {
+		return err
+	}
+	kubeconfig, err := loadKubeConfig(target.File)
+	if err != nil {
+		return fmt.Errorf("could not load %s: %v", target.File, err)
+	}
+
+	currentContext = target.Context
+	record := buildContextRecord(kubeconfig, target.Context, target.File)
+	detail, _ := findContextDetail(kubeconfig, target.Context)
+	metadata, _ := loadMetadataFile()
+	record.Tags = mergedContextTags(detail, target.Context, metadata)
+	return printCurrentContextRecord(record)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var pickerBackends = []string{"auto", "promptui", "fzf", "sk", "numbered"}
+
+// PickerItem is one choice in a picker. Key identifies the item to the
+// preview command (a context name) and may be empty. Items with a Section are
+// shown under that section; Details is shown for the highlighted item. Boost
+// is added to the item's fuzzy score while searching.
+type PickerItem struct {
+	Label   string
+	Key     string
+	Section string
+	Details string
+	Boost   int
+}
+
+// PickerKey is an extra key binding, named the way fzf names keys
+// ("ctrl-o"). Pressing it ends the pick like Enter, but reports the key.
+type PickerKey struct {
+	Name        string
+	Description string
+}
+
+type PickRequest struct {
+	Label string
+	Items []PickerItem
+	// Size is the number of visible rows for backends that page.
+	Size int
+	// Preview enables `kjx preview <key>` in backends that support it.
+	Preview bool
+	// StartInSearch opens the built-in picker with the search prompt active.
+	StartInSearch bool
+	Keys          []PickerKey
+}
+
+// PickResult is the chosen item and the key binding used to choose it, which
+// is empty for Enter.
+type PickResult struct {
+	Index int
+	Key   string
+}
+
+// Picker lets the user choose one item.
+type Picker interface {
+	Pick(request PickRequest) (PickResult, error)
+}
+
+func pickerLabels(items []PickerItem) []string {
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.Label
+	}
+	return labels
+}
+
+// pickerDisplayLabels prefixes each label with its section as an aligned
+// column, so sections stay visible (and searchable) in flat pickers.
+func pickerDisplayLabels(items []PickerItem) []string {
+	width := 0
+	for _, item := range items {
+		if len(item.Section) > width {
+			width = len(item.Section)
+		}
+	}
+	if width == 0 {
+		return pickerLabels(items)
+	}
+
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = fmt.Sprintf("%-*s │ %s", width, item.Section, item.Label)
+	}
+	return labels
+}
+
+func pickerBoosts(items []PickerItem) []int {
+	boosts := make([]int, len(items))
+	for i, item := range items {
+		boosts[i] = item.Boost
+	}
+	return boosts
+}
+
+func describePickerKeys(keys []PickerKey) string {
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key.Name+" "+key.Description)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// promptuiPicker is the built-in fuzzy picker.
+type promptuiPicker struct{}
+
+func (promptuiPicker) Pick(request PickRequest) (PickResult, error) {
+	return fuzzySelect(request)
+}
+
+// externalPicker pipes the items into fzf or skim, which share the options
+// used here. Each line carries the item index and key in hidden fields.
+type externalPicker struct {
+	command string
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func (p externalPicker) Pick(request PickRequest) (PickResult, error) {
+	var input bytes.Buffer
+	for i, label := range pickerDisplayLabels(request.Items) {
+		fmt.Fprintf(&input, "%d\t%s\t%s\n", i, request.Items[i].Key, label)
+	}
+
+	args := []string{
+		"--delimiter", "\t",
+		"--with-nth", "3..",
+		"--prompt", request.Label + "> ",
+		"--height", "40%",
+		"--reverse",
+		"--ansi",
+	}
+	if request.Preview {
+		executable, err := os.Executable()
+		if err == nil {
+			dir, _ := filepath.Abs(configDir)
+			args = append(args, "--preview", fmt.Sprintf("%s preview -d %s {2}", shellQuote(executable), shellQuote(dir)))
+		}
+	}
+	if len(request.Keys) > 0 {
+		var names []string
+		for _, key := range request.Keys {
+			names = append(names, key.Name)
+		}
+		args = append(args, "--expect", strings.Join(names, ","), "--header", describePickerKeys(request.Keys))
+	}
+
+	// stderr is passed through for the picker's UI and kept for the error
+	// message if it fails.
+	var stderr bytes.Buffer
+	cmd := exec.Command(p.command, args...)
+	cmd.Stdin = &input
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// fzf and sk exit with 1 when nothing matched and 130 on Esc/Ctrl-C;
+		// anything else, like 2 for a bad option or tty, is a real failure.
+		status := exitErr.ExitCode()
+		if status == 1 || status == 130 {
+			return PickResult{}, abortedError("no selection made (%s exited with status %d)", p.command, status)
+		}
+		return PickResult{}, fmt.Errorf("%s failed with status %d: %s", p.command, status, lastLine(stderr.String()))
+	}
+	if err != nil {
+		return PickResult{}, fmt.Errorf("running %s: %v", p.command, err)
+	}
+
+	// With --expect the first line is the key that was pressed.
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	var result PickResult
+	if len(request.Keys) > 0 && len(lines) > 1 {
+		result.Key = lines[0]
+		lines = lines[1:]
+	}
+
+	fields := strings.SplitN(lines[len(lines)-1], "\t", 2)
+	index, err := strconv.Atoi(fields[0])
+	if err != nil || index < 0 || index >= len(request.Items) {
+		return PickResult{}, fmt.Errorf("unexpected output from %s: %q", p.command, string(output))
+	}
+	result.Index = index
+	return result, nil
+}
+
+// lastLine returns the last non-empty line of output, which is where pickers
+// put their error message after any UI they drew.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// numberedPicker prints a numbered list and reads the choice from stdin, for
+// dumb terminals and pipes. Typing text instead of a number narrows the list.
+// Extra key bindings are not available here.
+type numberedPicker struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (p numberedPicker) Pick(request PickRequest) (PickResult, error) {
+	labels := pickerLabels(request.Items)
+	searchLabels := pickerDisplayLabels(request.Items)
+	reader := bufio.NewReader(p.in)
+
+	shown := make([]int, len(labels))
+	for i := range labels {
+		shown[i] = i
+	}
+
+	for {
+		section := ""
+		for n, index := range shown {
+			if item := request.Items[index]; item.Section != "" && item.Section != section {
+				section = item.Section
+				fmt.Fprintf(p.out, "%s:\n", section)
+			}
+			fmt.Fprintf(p.out, "%3d) %s\n", n+1, labels[index])
+		}
+		fmt.Fprintf(p.out, "%s [1-%d, text to filter]: ", request.Label, len(shown))
+
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err != nil {
+			fmt.Fprintln(p.out)
+			return PickResult{}, abortedError("no selection made")
+		}
+
+		if n, convErr := strconv.Atoi(line); convErr == nil {
+			if n >= 1 && n <= len(shown) {
+				return PickResult{Index: shown[n-1]}, nil
+			}
+			fmt.Fprintf(p.out, "❌ %d is out of range\n\n", n)
+			continue
+		}
+
+		if line == "" {
+			// An empty line goes back to the full list.
+			shown = shown[:0]
+			for i := range labels {
+				shown = append(shown, i)
+			}
+			fmt.Fprintln(p.out)
+			continue
+		}
+
+		matches := rankFuzzyMatches(searchLabels, line)
+		boostFuzzyMatches(matches, pickerBoosts(request.Items))
+		var filtered []int
+		for _, match := range matches {
+			filtered = append(filtered, match.Index)
+		}
+		if len(filtered) == 0 {
+			fmt.Fprintf(p.out, "❌ Nothing matches '%s'\n\n", line)
+			continue
+		}
+		if len(filtered) == 1 {
+			return PickResult{Index: filtered[0]}, nil
+		}
+		shown = filtered
+		fmt.Fprintln(p.out)
+	}
+}
+
+// configuredPickerName returns the backend from KJX_PICKER, falling back to
+// the picker setting in config.yaml.
+func configuredPickerName() string {
+	if name := os.Getenv("KJX_PICKER"); name != "" {
+		return name
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return "auto"
+	}
+	if settings.Picker != "" {
+		return settings.Picker
+	}
+	return "auto"
+}
+
+func selectPicker() (Picker, error) {
+	name := configuredPickerName()
+
+	switch name {
+	case "fzf", "sk":
+		if _, err := exec.LookPath(name); err == nil {
+			return externalPicker{command: name}, nil
+		}
+		fmt.Fprintf(os.Stderr, "Warning: picker '%s' not found in PATH, using the built-in picker\n", name)
+	case "promptui":
+		return promptuiPicker{}, nil
+	case "numbered":
+		return numberedPicker{in: os.Stdin, out: os.Stderr}, nil
+	case "auto":
+	default:
+		return nil, fmt.Errorf("unknown picker '%s' (expected one of: %s)", name, strings.Join(pickerBackends, ", "))
+	}
+
+	if os.Getenv("TERM") == "dumb" || !stdinIsTerminal() {
+		return numberedPicker{in: os.Stdin, out: os.Stderr}, nil
+	}
+	return promptuiPicker{}, nil
+}
+
+func pick(request PickRequest) (PickResult, error) {
+	if len(request.Items) == 0 {
+		return PickResult{}, notFoundError("nothing to choose from")
+	}
+	picker, err := selectPicker()
+	if err != nil {
+		return PickResult{}, err
+	}
+	return picker.Pick(request)
+}
+
+func plainPickerItems(labels []string) []PickerItem {
+	items := make([]PickerItem, len(labels))
+	for i, label := range labels {
+		items[i] = PickerItem{Label: label}
+	}
+	return items
+}
+
+// printContextPreview shows what a context points at; it backs the preview
+// window of external pickers.
+func printContextPreview(configInfos []ConfigInfo, contextName string) error {
+	filePath := findContextFile(configInfos, contextName)
+	if filePath == "" {
+		return notFoundError("context '%s' not found", contextName)
+	}
+
+	kubeconfig, err := loadKubeConfig(filePath)
+	if err != nil {
+		return fmt.Errorf("loading %s: %v", filepath.Base(filePath), err)
+	}
+	record := buildContextRecord(kubeconfig, contextName, filePath)
+
+	tierIndicator := ""
+	if record.Tier == "prod" {
+		tierIndicator = " 🔴"
+	}
+
+	fmt.Printf("🔹 Context: %s\n", record.Name)
+	fmt.Printf("📁 Config File: %s\n", filepath.Base(record.File))
+	fmt.Printf("🏗️  Cluster: %s\n", record.Cluster)
+	fmt.Printf("🌐 Server: %s\n", record.Server)
+	fmt.Printf("🔑 User: %s\n", record.User)
+	fmt.Printf("📦 Namespace: %s\n", record.Namespace)
+	fmt.Printf("🚦 Tier: %s%s\n", record.Tier, tierIndicator)
+	showContextMetadata(contextTags(configInfos, contextName))
+	showCredentialExpiry(filePath, contextName)
+	return nil
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+	currentContext = getCurrentContext()
+	return printContextPreview(configInfos, args[0])
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Raw kubeconfig helpers. Commands that rewrite kubeconfig files work on the
+// untyped YAML tree so fields kjx does not model are preserved verbatim.
+
+func loadRawKubeConfig(filePath string) (map[interface{}]interface{}, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawConfig interface{}
+	if err := yaml.Unmarshal(data, &rawConfig); err != nil {
+		return nil, err
+	}
+
+	if rawConfig == nil {
+		return make(map[interface{}]interface{}), nil
+	}
+
+	configMap, ok := rawConfig.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a kubeconfig mapping", filePath)
+	}
+
+	return configMap, nil
+}
+
+// newRawKubeConfig is the skeleton of a kubeconfig file without entries.
+func newRawKubeConfig() map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		"apiVersion":      "v1",
+		"kind":            "Config",
+		"current-context": "",
+		"preferences":     map[interface{}]interface{}{},
+	}
+}
+
+func saveRawKubeConfig(filePath string, configMap map[interface{}]interface{}, perm os.FileMode) error {
+	data, err := yaml.Marshal(configMap)
+	if err != nil {
+		return err
+	}
+
+	return writeConfigFile(filePath, data, perm)
+}
+
+// writeConfigFile atomically replaces filePath, keeping the permissions of an
+// existing file and using perm for a new one.
+func writeConfigFile(filePath string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	return writeFileAtomic(filePath, data, perm)
+}
+
+// rawNamedEntries returns the named entries under key ("clusters", "contexts"
+// or "users"), skipping anything that is not a mapping.
+func rawNamedEntries(configMap map[interface{}]interface{}, key string) []map[interface{}]interface{} {
+	list, ok := configMap[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var entries []map[interface{}]interface{}
+	for _, item := range list {
+		if entry, ok := item.(map[interface{}]interface{}); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func setRawNamedEntries(configMap map[interface{}]interface{}, key string, entries []map[interface{}]interface{}) {
+	list := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	configMap[key] = list
+}
+
+func rawEntryName(entry map[interface{}]interface{}) string {
+	name, _ := entry["name"].(string)
+	return name
+}
+
+func findRawEntry(configMap map[interface{}]interface{}, key, name string) map[interface{}]interface{} {
+	for _, entry := range rawNamedEntries(configMap, key) {
+		if rawEntryName(entry) == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// rawEntryDetail returns the nested detail mapping of an entry, e.g. the
+// "cluster" mapping of a clusters entry.
+func rawEntryDetail(entry map[interface{}]interface{}, detailKey string) map[interface{}]interface{} {
+	if entry == nil {
+		return nil
+	}
+	detail, _ := entry[detailKey].(map[interface{}]interface{})
+	return detail
+}
+
+func rawString(m map[interface{}]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+// copyRaw deep-copies a YAML tree so edits to the copy never leak back into
+// the document it came from.
+func copyRaw(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyRaw(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyRaw(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over
+// filePath. A symlinked filePath (dotfile managers) is resolved first, so the
+// file it points to is replaced and the link is kept.
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = resolved
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tempFile := filePath + ".kjx-tmp"
+	if err := ioutil.WriteFile(tempFile, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, filePath)
+}
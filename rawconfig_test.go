@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteConfigFileKeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config")
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeConfigFile(link, []byte("new"), 0644); err != nil {
+		t.Fatalf("writeConfigFile: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s was replaced by a regular file", link)
+	}
+	data, err := ioutil.ReadFile(target)
+	if err != nil || string(data) != "new" {
+		t.Errorf("target = %q, %v; want %q", data, err, "new")
+	}
+	if info, err := os.Stat(target); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("target permissions = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.kjx-tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left next to the link: %v", err)
+	}
+}
+
+func TestWriteConfigFileCreatesNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.yaml")
+	if err := writeConfigFile(path, []byte("data"), 0600); err != nil {
+		t.Fatalf("writeConfigFile: %v", err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "data" {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchFirst makes a search with several matches take the best one.
+var searchFirst bool
+
+// searchFieldPenalty keeps a context whose name matches ahead of one that
+// only matches in another field.
+const searchFieldPenalty = 2 * fuzzyScoreMatch
+
+// searchFieldNames are the qualifiers a search term can start with, as in
+// "server:eks". "namespace:" is accepted as a longer spelling of "ns:".
+var searchFieldNames = []string{"name", "cluster", "server", "user", "ns", "file", "alias", "tag"}
+
+// SearchQuery is one word of a search term: plain text, or a /regex/,
+// optionally limited to one field.
+type SearchQuery struct {
+	Field string
+	Text  string
+	Regex *regexp.Regexp
+}
+
+// SearchField is a searchable value of a context.
+type SearchField struct {
+	Field string
+	Value string
+}
+
+// ContextMatch is a context found by searchContextMatches. Fields lists the
+// non-name fields that matched, so the result can say why it was found.
+type ContextMatch struct {
+	Name   string
+	Score  int
+	Fields []SearchField
+}
+
+func isSearchField(field string) bool {
+	for _, name := range searchFieldNames {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isSearchRegex(text string) bool {
+	return len(text) > 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/")
+}
+
+// splitSearchQualifier splits "server:eks" into its field and text. Terms
+// whose prefix is not a known field, like "dev:payments", are left alone.
+func splitSearchQualifier(word string) (string, string) {
+	i := strings.Index(word, ":")
+	if i <= 0 {
+		return "", word
+	}
+	field := strings.ToLower(word[:i])
+	if field == "namespace" {
+		field = "ns"
+	}
+	if !isSearchField(field) {
+		return "", word
+	}
+	return field, word[i+1:]
+}
+
+// splitSearchWords splits a search term at spaces. Quotes keep a value with
+// spaces together and are dropped: user:"jane doe" is one word.
+func splitSearchWords(term string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range term {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing %c in search term '%s'", quote, term)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseSearchTerm turns a search term into queries that must all match. A
+// term without qualifiers or regexes stays a single fuzzy query, so spaces
+// keep working the way they always have ("prod pay" finds prod-payments).
+func parseSearchTerm(term string) ([]SearchQuery, error) {
+	words, splitErr := splitSearchWords(term)
+	if splitErr != nil {
+		// A stray quote only matters in a structured term.
+		words = strings.Fields(term)
+	}
+	structured := false
+	for _, word := range words {
+		field, text := splitSearchQualifier(word)
+		if field != "" || isSearchRegex(text) {
+			structured = true
+			break
+		}
+	}
+	if !structured {
+		return []SearchQuery{{Text: term}}, nil
+	}
+	if splitErr != nil {
+		return nil, splitErr
+	}
+
+	var queries []SearchQuery
+	for _, word := range words {
+		field, text := splitSearchQualifier(word)
+		query := SearchQuery{Field: field, Text: text}
+		if isSearchRegex(text) {
+			regex, err := regexp.Compile("(?i)" + text[1:len(text)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression '%s': %v", text, err)
+			}
+			query.Regex = regex
+		} else if field != "" && text == "" {
+			return nil, fmt.Errorf("missing search text after '%s:'", field)
+		}
+		queries = append(queries, query)
+	}
+	return queries, nil
+}
+
+// serverHost reduces a server URL to its host name, which is what people
+// remember ("eks", "10.0.0.1") rather than the scheme and port.
+func serverHost(server string) string {
+	if parsed, err := url.Parse(server); err == nil && parsed.Hostname() != "" {
+		return parsed.Hostname()
+	}
+	return server
+}
+
+// searchFields lists the values of a context that search looks at, name
+// first. The alias is the "alias" tag (kjx tag <context> alias=<name>).
+func searchFields(record ContextRecord) []SearchField {
+	fields := []SearchField{
+		{"name", record.Name},
+		{"cluster", record.Cluster},
+		{"server", serverHost(record.Server)},
+		{"user", record.User},
+		{"ns", record.Namespace},
+		{"file", filepath.Base(record.File)},
+	}
+	if alias := record.Tags["alias"]; alias != "" {
+		fields = append(fields, SearchField{"alias", alias})
+	}
+	keys := make([]string, 0, len(record.Tags))
+	for key := range record.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fields = append(fields, SearchField{"tag", key + "=" + record.Tags[key]})
+	}
+	return fields
+}
+
+// matchSearchField scores one query against one field. Only an unqualified
+// query matches the name fuzzily. Qualified queries and other fields have to
+// contain the text, otherwise short terms would match nearly every server URL.
+func matchSearchField(query SearchQuery, field SearchField) (int, bool) {
+	if field.Value == "" {
+		return 0, false
+	}
+	if query.Regex != nil {
+		return fuzzyScoreMatch, query.Regex.MatchString(field.Value)
+	}
+	if query.Field != "" || field.Field != "name" {
+		if !strings.Contains(strings.ToLower(field.Value), strings.ToLower(query.Text)) {
+			return 0, false
+		}
+	}
+	score, _, ok := fuzzyMatch(query.Text, field.Value)
+	if ok && strings.EqualFold(query.Text, field.Value) {
+		score += fuzzyScoreMatch
+	}
+	return score, ok
+}
+
+// matchSearchQuery finds the best field of a context for query.
+func matchSearchQuery(query SearchQuery, fields []SearchField) (SearchField, int, bool) {
+	var best SearchField
+	bestScore, found := 0, false
+	for _, field := range fields {
+		if query.Field != "" && field.Field != query.Field {
+			continue
+		}
+		score, ok := matchSearchField(query, field)
+		if !ok {
+			continue
+		}
+		if field.Field != "name" && query.Field == "" {
+			score -= searchFieldPenalty
+		}
+		if !found || score > bestScore {
+			best, bestScore, found = field, score, true
+		}
+	}
+	return best, bestScore, found
+}
+
+// searchContextMatches ranks contexts against a search term, nudged by pins
+// and frecency. Every query in the term has to match some field.
+func searchContextMatches(configInfos []ConfigInfo, searchTerm string) ([]ContextMatch, error) {
+	queries, err := parseSearchTerm(searchTerm)
+	if err != nil {
+		return nil, err
+	}
+
+	names := allContextNames(configInfos)
+	records := buildContextRecords(configInfos, names)
+	state := loadStateOrEmpty()
+	now := time.Now()
+
+	empty := len(queries) == 1 && queries[0].Regex == nil && len(normalizeFuzzyPattern(queries[0].Text)) == 0
+	var ranked []FuzzyMatch
+	matched := make(map[int][]SearchField)
+	boosts := make([]int, len(names))
+	for i, record := range records {
+		boosts[i] = state.usageBoost(record.Name, now)
+		if empty {
+			ranked = append(ranked, FuzzyMatch{Index: i, Text: record.Name})
+			continue
+		}
+
+		fields := searchFields(record)
+		total, ok := 0, true
+		for _, query := range queries {
+			field, score, found := matchSearchQuery(query, fields)
+			if !found {
+				ok = false
+				break
+			}
+			total += score
+			if field.Field != "name" {
+				matched[i] = append(matched[i], field)
+			}
+		}
+		if ok {
+			ranked = append(ranked, FuzzyMatch{Index: i, Text: record.Name, Score: total})
+		}
+	}
+	boostFuzzyMatches(ranked, boosts)
+
+	matches := make([]ContextMatch, len(ranked))
+	for i, match := range ranked {
+		matches[i] = ContextMatch{Name: match.Text, Score: match.Score, Fields: matched[match.Index]}
+	}
+	return matches, nil
+}
+
+// formatSearchFields describes why a context matched, e.g. "server: eks-dev".
+func formatSearchFields(fields []SearchField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Field + ": " + field.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// searchChoice returns the match a search settles on by itself: the one
+// numbered by the second argument ("kjx -s prod 2"), the first with --first,
+// or the only one. It returns -1 when the user still has to choose.
+func searchChoice(args []string, count int, what string) (int, error) {
+	if len(args) > 2 {
+		return 0, fmt.Errorf("expected a search term and at most a match number, got %d arguments", len(args))
+	}
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid match number '%s' (expected 1-%d)", args[1], count)
+		}
+		if n > count {
+			return 0, notFoundError("no match %d for '%s' (%d %s found)", n, args[0], count, what)
+		}
+		return n - 1, nil
+	}
+	if searchFirst || count == 1 {
+		return 0, nil
+	}
+	return -1, nil
+}
+
+// searchAmbiguousError explains how to settle a search with several matches.
+func searchAmbiguousError(command string, count int, what, term string) error {
+	return ambiguousError("%d %s match '%s'; refine the search term, pick one with '%s %s <n>' or use --first", count, what, term, command, term)
+}
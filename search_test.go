@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// describeQueries renders queries as "field|text|regex" for comparison.
+func describeQueries(queries []SearchQuery) []string {
+	var described []string
+	for _, query := range queries {
+		regex := ""
+		if query.Regex != nil {
+			regex = query.Regex.String()
+		}
+		described = append(described, query.Field+"|"+query.Text+"|"+regex)
+	}
+	return described
+}
+
+func TestParseSearchTerm(t *testing.T) {
+	tests := []struct {
+		name string
+		term string
+		want []string
+	}{
+		{"plain term stays one fuzzy query", "prod pay", []string{"|prod pay|"}},
+		{"unknown prefix is plain text", "dev:payments", []string{"|dev:payments|"}},
+		{"stray quote in plain term", "o'brien", []string{"|o'brien|"}},
+		{"qualifier", "server:eks", []string{"server|eks|"}},
+		{"qualifier is case-insensitive", "Server:eks", []string{"server|eks|"}},
+		{"namespace spelled out", "namespace:payments", []string{"ns|payments|"}},
+		{"several terms", "file:team-a user:admin", []string{"file|team-a|", "user|admin|"}},
+		{"qualified and plain", "ns:payments prod", []string{"ns|payments|", "|prod|"}},
+		{"double-quoted value", `user:"jane doe"`, []string{"user|jane doe|"}},
+		{"single-quoted value", `tag:'team=pay ments' eu`, []string{"tag|team=pay ments|", "|eu|"}},
+		{"tag key and value", "tag:team=payments", []string{"tag|team=payments|"}},
+		{"regex", "/^prod-(eu|us)$/", []string{"|/^prod-(eu|us)$/|(?i)^prod-(eu|us)$"}},
+		{"qualified regex", `server:/\.internal$/`, []string{`server|/\.internal$/|(?i)\.internal$`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, err := parseSearchTerm(test.term)
+			if err != nil {
+				t.Fatalf("parseSearchTerm(%q): %v", test.term, err)
+			}
+			if got := describeQueries(queries); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSearchTerm(%q) = %q, want %q", test.term, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchTermErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		term string
+		want string
+	}{
+		{"missing text", "server:", "missing search text after 'server:'"},
+		{"empty quoted text", `user:""`, "missing search text after 'user:'"},
+		{"invalid regex", "/[a/", "invalid regular expression '/[a/'"},
+		{"invalid qualified regex", "name:/(/", "invalid regular expression '/(/'"},
+		{"unclosed quote", `user:"jane doe`, "missing closing \""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseSearchTerm(test.term)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("parseSearchTerm(%q) error = %v, want it to contain %q", test.term, err, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchSearchField(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SearchQuery
+		field SearchField
+		match bool
+	}{
+		{"unqualified name is fuzzy", SearchQuery{Text: "pdpay"}, SearchField{"name", "prod-payments"}, true},
+		{"unqualified other field needs a substring", SearchQuery{Text: "eas"}, SearchField{"server", "x.eks.amazonaws.com"}, false},
+		{"unqualified other field substring", SearchQuery{Text: "eks"}, SearchField{"server", "x.eks.amazonaws.com"}, true},
+		{"qualified name is not fuzzy", SearchQuery{Field: "name", Text: "pdpay"}, SearchField{"name", "prod-payments"}, false},
+		{"qualified name substring", SearchQuery{Field: "name", Text: "pay"}, SearchField{"name", "prod-payments"}, true},
+		{"qualified server is not fuzzy", SearchQuery{Field: "server", Text: "eas"}, SearchField{"server", "x.eks.amazonaws.com"}, false},
+		{"qualified match is case-insensitive", SearchQuery{Field: "user", Text: "ADMIN"}, SearchField{"user", "eks-admin"}, true},
+		{"value with spaces", SearchQuery{Field: "user", Text: "jane doe"}, SearchField{"user", "Jane Doe"}, true},
+		{"value with spaces needs the spaces", SearchQuery{Field: "user", Text: "jane doe"}, SearchField{"user", "jane-doe"}, false},
+		{"tag key and value", SearchQuery{Field: "tag", Text: "team=payments"}, SearchField{"tag", "team=payments"}, true},
+		{"tag value must match", SearchQuery{Field: "tag", Text: "team=payments"}, SearchField{"tag", "team=pay"}, false},
+		{"regex", SearchQuery{Regex: regexp.MustCompile(`(?i)\.internal$`)}, SearchField{"server", "api.INTERNAL"}, true},
+		{"regex anchored", SearchQuery{Regex: regexp.MustCompile(`(?i)\.internal$`)}, SearchField{"server", "internal.example.com"}, false},
+		{"empty field", SearchQuery{Text: "dev"}, SearchField{"ns", ""}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := matchSearchField(test.query, test.field); ok != test.match {
+				t.Errorf("matchSearchField(%+v, %+v) matched = %v, want %v", test.query, test.field, ok, test.match)
+			}
+		})
+	}
+}
+
+func TestMatchSearchQueryTags(t *testing.T) {
+	fields := searchFields(ContextRecord{
+		Name:    "prod-eu",
+		Cluster: "eks-prod",
+		Server:  "https://abc.eks.amazonaws.com:443",
+		Tags:    map[string]string{"team": "payments", "alias": "pay"},
+	})
+
+	tests := []struct {
+		term  string
+		field SearchField
+		match bool
+	}{
+		{"tag:team=payments", SearchField{"tag", "team=payments"}, true},
+		{"tag:team=", SearchField{"tag", "team=payments"}, true},
+		{"tag:team=billing", SearchField{}, false},
+		{"alias:pay", SearchField{"alias", "pay"}, true},
+		{"server:amazonaws", SearchField{"server", "abc.eks.amazonaws.com"}, true},
+		{"server:https", SearchField{}, false},
+		{"cluster:ekp", SearchField{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.term, func(t *testing.T) {
+			queries, err := parseSearchTerm(test.term)
+			if err != nil {
+				t.Fatal(err)
+			}
+			field, _, ok := matchSearchQuery(queries[0], fields)
+			if ok != test.match || (ok && field != test.field) {
+				t.Errorf("matchSearchQuery(%q) = %+v, %v; want %+v, %v", test.term, field, ok, test.field, test.match)
+			}
+		})
+	}
+}
+
+func TestSearchChoice(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		count int
+		first bool
+		want  int
+		code  int
+	}{
+		{"only match", []string{"pay"}, 1, false, 0, exitOK},
+		{"several matches", []string{"pay"}, 3, false, -1, exitOK},
+		{"first", []string{"pay"}, 3, true, 0, exitOK},
+		{"numbered pick", []string{"pay", "2"}, 3, false, 1, exitOK},
+		{"numbered pick beats first", []string{"pay", "3"}, 3, true, 2, exitOK},
+		{"out of range", []string{"pay", "4"}, 3, false, 0, exitNotFound},
+		{"zero", []string{"pay", "0"}, 3, false, 0, exitError},
+		{"not a number", []string{"pay", "two"}, 3, false, 0, exitError},
+		{"too many arguments", []string{"pay", "1", "2"}, 3, false, 0, exitError},
+	}
+	defer func(first bool) { searchFirst = first }(searchFirst)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			searchFirst = test.first
+			got, err := searchChoice(test.args, test.count, "contexts")
+			if code := exitCodeFor(err); code != test.code {
+				t.Fatalf("searchChoice(%q) exit code = %d (%v), want %d", test.args, code, err, test.code)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("searchChoice(%q) = %d, want %d", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchChoiceOutOfRangeMessage(t *testing.T) {
+	_, err := searchChoice([]string{"pay", "5"}, 2, "namespaces")
+	if err == nil || err.Error() != "no match 5 for 'pay' (2 namespaces found)" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestSearchAmbiguousError(t *testing.T) {
+	err := searchAmbiguousError("kjx ns -s", 2, "namespaces", "pay")
+	if code := exitCodeFor(err); code != exitAmbiguous {
+		t.Errorf("exit code = %d, want %d", code, exitAmbiguous)
+	}
+	want := "2 namespaces match 'pay'; refine the search term, pick one with 'kjx ns -s pay <n>' or use --first"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err.Error(), want)
+	}
+}
+
+const searchTestConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+users:
+- name: %[1]s
+  user:
+    token: t
+`
+
+// writeSearchConfigs writes one kubeconfig file per context, without a
+// current-context, so a switch shows in the file it picked.
+func writeSearchConfigs(t *testing.T, contexts ...string) string {
+	dir := t.TempDir()
+	for _, name := range contexts {
+		data := []byte(fmt.Sprintf(searchTestConfig, name))
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yaml"), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runSearchWithoutTerminal runs `kjx -s <args>` against the config dir, with
+// stdin on /dev/null, and returns what it printed. The selected file is
+// written to <dir>/selected instead of /tmp/kjx-config.
+func runSearchWithoutTerminal(t *testing.T, dir string, args ...string) (string, error) {
+	t.Setenv("KJX_HOME", t.TempDir())
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "missing"))
+
+	defer func(dir, selected string, search bool, stdin, stdout *os.File) {
+		configDir, outputConfig, searchMode, os.Stdin, os.Stdout = dir, selected, search, stdin, stdout
+	}(configDir, outputConfig, searchMode, os.Stdin, os.Stdout)
+	configDir, outputConfig, searchMode = dir, filepath.Join(dir, "selected"), true
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdin, os.Stdout = devNull, writer
+
+	runErr := runContextSwitcher(nil, args)
+	writer.Close()
+	output, _ := ioutil.ReadAll(reader)
+	return string(output), runErr
+}
+
+// assertSwitchedTo checks that contextName was made current in its own file
+// and that the other files were left alone.
+func assertSwitchedTo(t *testing.T, dir, contextName string, contexts []string) {
+	t.Helper()
+	selected, err := ioutil.ReadFile(filepath.Join(dir, "selected"))
+	if err != nil || string(selected) != filepath.Join(dir, contextName+".yaml") {
+		t.Errorf("selected file = %q, %v; want %s.yaml", selected, err, contextName)
+	}
+	for _, name := range contexts {
+		config, err := loadKubeConfig(filepath.Join(dir, name+".yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		if name == contextName {
+			want = contextName
+		}
+		if config.CurrentContext != want {
+			t.Errorf("current-context in %s.yaml = %q, want %q", name, config.CurrentContext, want)
+		}
+	}
+}
+
+func TestSearchAmbiguousWithoutTerminal(t *testing.T) {
+	dir := writeSearchConfigs(t, "prod-payments", "dev-payments", "staging")
+	output, err := runSearchWithoutTerminal(t, dir, "payments")
+	if code := exitCodeFor(err); code != exitAmbiguous {
+		t.Fatalf("exit code = %d (%v), want %d", code, err, exitAmbiguous)
+	}
+	if !strings.Contains(err.Error(), "pick one with 'kjx -s payments <n>'") {
+		t.Errorf("error = %v", err)
+	}
+	for _, want := range []string{"Contexts matching 'payments':", "1) ", "2) ", "dev-payments", "prod-payments 🔴"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("listing is missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "staging") {
+		t.Errorf("listing shows a context that does not match:\n%s", output)
+	}
+}
+
+func TestSearchOutOfRangeWithoutTerminal(t *testing.T) {
+	contexts := []string{"prod-payments", "dev-payments"}
+	dir := writeSearchConfigs(t, contexts...)
+	_, err := runSearchWithoutTerminal(t, dir, "payments", "3")
+	if code := exitCodeFor(err); code != exitNotFound {
+		t.Fatalf("exit code = %d (%v), want %d", code, err, exitNotFound)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "selected")); !os.IsNotExist(err) {
+		t.Errorf("a failed search selected a kubeconfig file: %v", err)
+	}
+	for _, name := range contexts {
+		if config, err := loadKubeConfig(filepath.Join(dir, name+".yaml")); err != nil || config.CurrentContext != "" {
+			t.Errorf("a failed search switched %s.yaml: %v", name, err)
+		}
+	}
+}
+
+// Equal scores rank the shorter name first, so "payments" lists dev-payments
+// as 1 and prod-payments as 2.
+
+func TestSearchNumberedPickWithoutTerminal(t *testing.T) {
+	contexts := []string{"prod-payments", "dev-payments", "staging"}
+	dir := writeSearchConfigs(t, contexts...)
+	output, err := runSearchWithoutTerminal(t, dir, "payments", "2")
+	if err != nil {
+		t.Fatalf("kjx -s payments 2: %v", err)
+	}
+	if !strings.Contains(output, "Picked match 2. Switching to 'prod-payments'") {
+		t.Errorf("output does not announce the pick:\n%s", output)
+	}
+	assertSwitchedTo(t, dir, "prod-payments", contexts)
+}
+
+func TestSearchFirstWithoutTerminal(t *testing.T) {
+	defer func(first bool) { searchFirst = first }(searchFirst)
+	searchFirst = true
+
+	contexts := []string{"prod-payments", "dev-payments", "staging"}
+	dir := writeSearchConfigs(t, contexts...)
+	output, err := runSearchWithoutTerminal(t, dir, "payments")
+	if err != nil {
+		t.Fatalf("kjx -s payments --first: %v", err)
+	}
+	if !strings.Contains(output, "Picked the best match. Switching to 'dev-payments'") {
+		t.Errorf("output does not announce the pick:\n%s", output)
+	}
+	assertSwitchedTo(t, dir, "dev-payments", contexts)
+}
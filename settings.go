@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Settings is kjx's own configuration, read from config.yaml in the kjx home
+// directory. Unknown keys are ignored so older binaries keep working.
+type Settings struct {
+	Picker string        `yaml:"picker,omitempty"`
+	Audit  AuditSettings `yaml:"audit,omitempty"`
+	Hooks  HookSettings  `yaml:"hooks,omitempty"`
+	// StickyNamespace restores the last namespace of a context on switch;
+	// on unless set to false.
+	StickyNamespace *bool `yaml:"stickyNamespace,omitempty"`
+	// PickNamespace makes the context picker continue with a namespace
+	// picker, as if --ns was given.
+	PickNamespace bool `yaml:"pickNamespace,omitempty"`
+}
+
+// kjxHomeDir is where kjx keeps its own files (settings, state). KJX_HOME
+// overrides the default ~/.kube/kjx.
+func kjxHomeDir() string {
+	if home := os.Getenv("KJX_HOME"); home != "" {
+		return home
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".kube", "kjx")
+}
+
+func settingsFilePath() string {
+	return filepath.Join(kjxHomeDir(), "config.yaml")
+}
+
+func loadSettings() (*Settings, error) {
+	settings := &Settings{}
+
+	data, err := ioutil.ReadFile(settingsFilePath())
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", settingsFilePath(), err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// setEnv replaces or appends key in an environment list.
+func setEnv(env []string, key, value string) []string {
+	prefix := key + "="
+	for i, entry := range env {
+		if strings.HasPrefix(entry, prefix) {
+			env[i] = prefix + value
+			return env
+		}
+	}
+	return append(env, prefix+value)
+}
+
+// shellPrompt is the marker exported as KJX_PROMPT for use in PS1.
+func shellPrompt(contextName, namespace, tier string) string {
+	target := contextName
+	if namespace != "" {
+		target += "/" + namespace
+	}
+	if tier == "prod" {
+		return fmt.Sprintf("[⎈ %s 🔴] ", target)
+	}
+	return fmt.Sprintf("[⎈ %s] ", target)
+}
+
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+
+	contextName, namespace, err := parseContextTarget(configInfos, args[0])
+	if err != nil {
+		return err
+	}
+	filePath := findContextFile(configInfos, contextName)
+	tier := environmentTier(contextName, filePath)
+
+	level := 1
+	if parent := os.Getenv("KJX_SHELL"); parent != "" {
+		if n, err := strconv.Atoi(os.Getenv("KJX_SHELL_LEVEL")); err == nil {
+			level = n + 1
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Already in a kjx shell for '%s'; this one is nested (level %d), exit returns to '%s'\n", parent, level, parent)
+	}
+
+	if tier == "prod" {
+		showProductionWarning(contextName, filePath)
+	}
+
+	kubeconfigPath, cleanup, err := writeIsolatedKubeConfig(configInfos, contextName, namespace)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	env := isolatedEnv(kubeconfigPath, contextName, namespace)
+	env = setEnv(env, "KJX_SHELL", contextName)
+	env = setEnv(env, "KJX_SHELL_LEVEL", strconv.Itoa(level))
+	env = setEnv(env, "KJX_TIER", tier)
+	env = setEnv(env, "KJX_PROMPT", shellPrompt(contextName, namespace, tier))
+
+	shell := userShell()
+	child := exec.Command(shell)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	fmt.Printf("🐚 Starting %s in context '%s'", shell, contextName)
+	if namespace != "" {
+		fmt.Printf(" (namespace '%s')", namespace)
+	}
+	fmt.Println(" - type 'exit' to leave")
+
+	status, err := runCommandStatus(child)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("👋 Left the kjx shell for '%s'\n", contextName)
+
+	if status != 0 {
+		return &ExitStatus{Code: status}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// State is what kjx remembers between runs, kept in state.yaml in the kjx
+// home directory. Favorites are the pinned contexts; Namespaces holds the
+// namespace last switched to in each context.
+type State struct {
+	Favorites  []string                `yaml:"favorites,omitempty"`
+	Usage      map[string]ContextUsage `yaml:"usage,omitempty"`
+	Namespaces map[string]string       `yaml:"namespaces,omitempty"`
+}
+
+// ContextUsage counts how often and how recently a context was switched to.
+type ContextUsage struct {
+	Count    int       `yaml:"count"`
+	LastUsed time.Time `yaml:"lastUsed"`
+}
+
+func stateFilePath() string {
+	return filepath.Join(kjxHomeDir(), "state.yaml")
+}
+
+func loadState() (*State, error) {
+	state := &State{}
+
+	data, err := ioutil.ReadFile(stateFilePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", stateFilePath(), err)
+	}
+	return state, nil
+}
+
+func saveState(state *State) error {
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(kjxHomeDir(), 0700); err != nil {
+		return err
+	}
+	return writeConfigFile(stateFilePath(), data, 0600)
+}
+
+func (s *State) isFavorite(contextName string) bool {
+	for _, favorite := range s.Favorites {
+		if favorite == contextName {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleFavorite adds or removes a favorite and reports whether the context
+// is a favorite now.
+func (s *State) toggleFavorite(contextName string) bool {
+	for i, favorite := range s.Favorites {
+		if favorite == contextName {
+			s.Favorites = append(s.Favorites[:i], s.Favorites[i+1:]...)
+			return false
+		}
+	}
+	s.Favorites = append(s.Favorites, contextName)
+	return true
+}
+
+func (s *State) recordUse(contextName string, now time.Time) {
+	if s.Usage == nil {
+		s.Usage = make(map[string]ContextUsage)
+	}
+	usage := s.Usage[contextName]
+	usage.Count++
+	usage.LastUsed = now
+	s.Usage[contextName] = usage
+}
+
+func (s *State) setNamespace(contextName, namespace string) {
+	if s.Namespaces == nil {
+		s.Namespaces = make(map[string]string)
+	}
+	s.Namespaces[contextName] = namespace
+}
+
+// frecency weighs the use count by how recently the context was used, so a
+// context used a lot last month ranks below one used a few times today.
+func (s *State) frecency(contextName string, now time.Time) float64 {
+	usage, ok := s.Usage[contextName]
+	if !ok {
+		return 0
+	}
+
+	age := now.Sub(usage.LastUsed)
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(usage.Count) * weight
+}
+
+// usageBoost is added to fuzzy scores so pinned and frequently used contexts
+// win close matches without outranking clearly better ones.
+func (s *State) usageBoost(contextName string, now time.Time) int {
+	boost := int(s.frecency(contextName, now))
+	if boost > 15 {
+		boost = 15
+	}
+	if s.isFavorite(contextName) {
+		boost += 20
+	}
+	return boost
+}
+
+// sortByUsage orders names pinned first, then by frecency, then by name.
+func (s *State) sortByUsage(names []string, now time.Time) {
+	sort.SliceStable(names, func(i, j int) bool {
+		pinnedI, pinnedJ := s.isFavorite(names[i]), s.isFavorite(names[j])
+		if pinnedI != pinnedJ {
+			return pinnedI
+		}
+		frecencyI, frecencyJ := s.frecency(names[i], now), s.frecency(names[j], now)
+		if frecencyI != frecencyJ {
+			return frecencyI > frecencyJ
+		}
+		return names[i] < names[j]
+	})
+}
+
+// loadStateOrEmpty is for read-only uses of the state, where a broken state
+// file should only cost the ordering, not the command.
+func loadStateOrEmpty() *State {
+	state, err := loadState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return &State{}
+	}
+	return state
+}
+
+func recordContextUse(contextName string) {
+	state, err := loadState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not record usage of '%s': %v\n", contextName, err)
+		return
+	}
+	state.recordUse(contextName, time.Now())
+	if err := saveState(state); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not record usage of '%s': %v\n", contextName, err)
+	}
+}
+
+func recordContextNamespace(contextName, namespace string) {
+	state, err := loadState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not remember namespace of '%s': %v\n", contextName, err)
+		return
+	}
+	if state.Namespaces[contextName] == namespace {
+		return
+	}
+	state.setNamespace(contextName, namespace)
+	if err := saveState(state); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not remember namespace of '%s': %v\n", contextName, err)
+	}
+}
+
+// stickyNamespace returns the namespace to restore when switching to a
+// context, or "" when there is none or sticky namespaces are turned off.
+func stickyNamespace(contextName string) string {
+	if noStickyNamespace {
+		return ""
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if settings.StickyNamespace != nil && !*settings.StickyNamespace {
+		return ""
+	}
+	return loadStateOrEmpty().Namespaces[contextName]
+}
+
+// renameContextState moves pins, usage and namespaces to a renamed context; an empty
+// newName forgets the context.
+func renameContextState(oldName, newName string) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i, favorite := range state.Favorites {
+		if favorite == oldName {
+			if newName == "" {
+				state.Favorites = append(state.Favorites[:i], state.Favorites[i+1:]...)
+			} else {
+				state.Favorites[i] = newName
+			}
+			changed = true
+			break
+		}
+	}
+	if usage, ok := state.Usage[oldName]; ok {
+		delete(state.Usage, oldName)
+		if newName != "" {
+			state.Usage[newName] = usage
+		}
+		changed = true
+	}
+	if namespace, ok := state.Namespaces[oldName]; ok {
+		delete(state.Namespaces, oldName)
+		if newName != "" {
+			state.Namespaces[newName] = namespace
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return saveState(state)
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	configInfos, err := loadAllKubeConfigs()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfigs: %v", err)
+	}
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	pin := cmd.Name() == "pin"
+	for _, contextName := range args {
+		if pin && findContextFile(configInfos, contextName) == "" {
+			return notFoundError("context '%s' not found", contextName)
+		}
+		if state.isFavorite(contextName) == pin {
+			continue
+		}
+		state.toggleFavorite(contextName)
+		if pin {
+			fmt.Printf("📌 Pinned '%s'\n", contextName)
+		} else {
+			fmt.Printf("Unpinned '%s'\n", contextName)
+		}
+	}
+
+	return saveState(state)
+}